@@ -0,0 +1,111 @@
+package raven
+
+import "sync"
+
+// MaxBreadcrumbs the maximum number of breadcrumbs kept by a client. Older breadcrumbs
+// are discarded when the limit is reached. Used by NewClient.
+var MaxBreadcrumbs = 100
+
+// Breadcrumb defines Sentry's spec compliant interface holding a single Breadcrumb - https://docs.sentry.io/development/sdk-dev/interfaces/breadcrumbs/
+type Breadcrumb struct {
+	// Required, set automatically by Client.AddBreadcrumb if blank
+	Timestamp Timestamp `json:"timestamp"`
+
+	// Optional
+	Type     string                 `json:"type,omitempty"`
+	Category string                 `json:"category,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Level    Severity               `json:"level,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+}
+
+// Breadcrumbs defines Sentry's spec compliant interface holding Breadcrumbs information - https://docs.sentry.io/development/sdk-dev/interfaces/breadcrumbs/
+type Breadcrumbs struct {
+	// Required
+	Values []*Breadcrumb `json:"values"`
+}
+
+// Class provides name of implemented Sentry's interface
+func (b *Breadcrumbs) Class() string { return "breadcrumbs" }
+
+// breadcrumbBuffer is a bounded ring buffer of breadcrumbs
+type breadcrumbBuffer struct {
+	mu     sync.Mutex
+	max    int
+	values []*Breadcrumb
+	start  int
+}
+
+func newBreadcrumbBuffer(max int) *breadcrumbBuffer {
+	return &breadcrumbBuffer{max: max}
+}
+
+func (b *breadcrumbBuffer) add(breadcrumb *Breadcrumb) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.max <= 0 {
+		return
+	}
+	if len(b.values) < b.max {
+		b.values = append(b.values, breadcrumb)
+		return
+	}
+	// Buffer is full, overwrite the oldest breadcrumb
+	b.values[b.start] = breadcrumb
+	b.start = (b.start + 1) % len(b.values)
+}
+
+func (b *breadcrumbBuffer) setMax(max int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	values := b.ordered()
+	if len(values) > max {
+		values = values[len(values)-max:]
+	}
+	if max <= 0 {
+		values = nil
+	}
+	b.max, b.values, b.start = max, values, 0
+}
+
+// snapshot returns a copy of the breadcrumbs, oldest first
+func (b *breadcrumbBuffer) snapshot() []*Breadcrumb {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.ordered()
+}
+
+func (b *breadcrumbBuffer) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.values, b.start = nil, 0
+}
+
+// ordered must be called with b.mu held
+func (b *breadcrumbBuffer) ordered() []*Breadcrumb {
+	values := make([]*Breadcrumb, 0, len(b.values))
+	values = append(values, b.values[b.start:]...)
+	return append(values, b.values[:b.start]...)
+}
+
+// AddBreadcrumb records a breadcrumb which will be attached to all packets
+// captured afterwards, until it is pushed out by newer ones or the context is cleared.
+func (client *Client) AddBreadcrumb(breadcrumb *Breadcrumb) {
+	if client == nil {
+		return
+	}
+	client.context.AddBreadcrumb(breadcrumb)
+}
+
+// SetMaxBreadcrumbs updates how many breadcrumbs the client keeps
+func (client *Client) SetMaxBreadcrumbs(max int) {
+	client.context.SetMaxBreadcrumbs(max)
+}
+
+// AddBreadcrumb records a breadcrumb on the default *Client
+func AddBreadcrumb(breadcrumb *Breadcrumb) { DefaultClient.AddBreadcrumb(breadcrumb) }
+
+// SetMaxBreadcrumbs updates how many breadcrumbs the default *Client keeps
+func SetMaxBreadcrumbs(max int) { DefaultClient.SetMaxBreadcrumbs(max) }
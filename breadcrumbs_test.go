@@ -0,0 +1,107 @@
+package raven
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func breadcrumbMessages(breadcrumbs []*Breadcrumb) []string {
+	var messages []string
+	for _, b := range breadcrumbs {
+		messages = append(messages, b.Message)
+	}
+	return messages
+}
+
+func TestBreadcrumbBufferDiscardsOldest(t *testing.T) {
+	buffer := newBreadcrumbBuffer(3)
+	for _, message := range []string{"a", "b", "c", "d", "e"} {
+		buffer.add(&Breadcrumb{Message: message})
+	}
+
+	expected := []string{"c", "d", "e"}
+	if actual := breadcrumbMessages(buffer.snapshot()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("incorrect breadcrumbs: got %v, want %v", actual, expected)
+	}
+
+	buffer.setMax(2)
+	expected = []string{"d", "e"}
+	if actual := breadcrumbMessages(buffer.snapshot()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("incorrect breadcrumbs after shrinking: got %v, want %v", actual, expected)
+	}
+
+	buffer.add(&Breadcrumb{Message: "f"})
+	expected = []string{"e", "f"}
+	if actual := breadcrumbMessages(buffer.snapshot()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("incorrect breadcrumbs after shrinking: got %v, want %v", actual, expected)
+	}
+
+	buffer.clear()
+	if actual := buffer.snapshot(); len(actual) != 0 {
+		t.Errorf("expected no breadcrumbs after clear, got %v", breadcrumbMessages(actual))
+	}
+}
+
+func TestBreadcrumbBufferDisabled(t *testing.T) {
+	buffer := newBreadcrumbBuffer(0)
+	buffer.add(&Breadcrumb{Message: "a"})
+	if actual := buffer.snapshot(); len(actual) != 0 {
+		t.Errorf("expected no breadcrumbs, got %v", breadcrumbMessages(actual))
+	}
+}
+
+func TestBreadcrumbsJSON(t *testing.T) {
+	breadcrumbs := &Breadcrumbs{Values: []*Breadcrumb{{
+		Timestamp: Timestamp(time.Date(2000, 01, 01, 0, 0, 0, 0, time.UTC)),
+		Category:  "db",
+		Message:   "SELECT 1",
+		Level:     INFO,
+		Data:      map[string]interface{}{"rows": 1},
+	}}}
+
+	expected := `{"values":[{"timestamp":"2000-01-01T00:00:00.00","category":"db","message":"SELECT 1","level":"info","data":{"rows":1}}]}`
+	actual, _ := json.Marshal(breadcrumbs)
+	if string(actual) != expected {
+		t.Errorf("incorrect JSON: got %s, want %s", actual, expected)
+	}
+}
+
+func TestCaptureIncludesBreadcrumbs(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetMaxBreadcrumbs(2)
+
+	client.AddBreadcrumb(&Breadcrumb{Message: "first"})
+	client.AddBreadcrumb(&Breadcrumb{Message: "second"})
+	client.AddBreadcrumb(&Breadcrumb{Message: "third"})
+	client.CaptureMessage("boom", nil)
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected a single packet, got %d", len(transport.packets))
+	}
+	var breadcrumbs *Breadcrumbs
+	for _, inter := range transport.packets[0].Interfaces {
+		if b, ok := inter.(*Breadcrumbs); ok {
+			breadcrumbs = b
+		}
+	}
+	if breadcrumbs == nil {
+		t.Fatal("expected packet to include breadcrumbs")
+	}
+
+	expected := []string{"second", "third"}
+	if actual := breadcrumbMessages(breadcrumbs.Values); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("incorrect breadcrumbs: got %v, want %v", actual, expected)
+	}
+	if time.Time(breadcrumbs.Values[0].Timestamp).IsZero() {
+		t.Error("expected breadcrumb timestamp to be set")
+	}
+
+	client.ClearContext()
+	if interfaces := client.contextInterfaces(); len(interfaces) != 0 {
+		t.Errorf("expected no interfaces after ClearContext, got %v", interfaces)
+	}
+}
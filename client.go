@@ -4,6 +4,7 @@ package raven
 import (
 	"bytes"
 	"compress/zlib"
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/base64"
@@ -22,6 +23,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/certifi/gocertifi"
@@ -40,6 +42,12 @@ var (
 	ErrMissingUser           = errors.New("raven: dsn missing public key and/or password")
 	ErrMissingProjectID      = errors.New("raven: dsn missing project id")
 	ErrInvalidSampleRate     = errors.New("raven: sample rate should be between 0 and 1")
+	ErrRetryNotSupported     = errors.New("raven: transport does not support retry policies")
+	ErrRateLimited           = errors.New("raven: packet dropped because of server side rate limits")
+	ErrClientClosed          = errors.New("raven: client is closed")
+	ErrInvalidWorkers        = errors.New("raven: number of workers should be at least 1")
+	ErrPacketFiltered        = errors.New("raven: packet dropped by an event processor")
+	ErrInvalidTrace          = errors.New("raven: no goroutine found in trace")
 )
 
 // Severity used in the level attribute of a message
@@ -105,8 +113,27 @@ type Extra map[string]interface{}
 type outgoingPacket struct {
 	packet *Packet
 	ch     chan error
+
+	// Name of the file holding the packet in the client's spool, if any
+	spoolFile string
+}
+
+// pendingGroup is a sync.WaitGroup which also tells how many packets are pending,
+// so that Flush returns right away when there are none
+type pendingGroup struct {
+	sync.WaitGroup
+	count int32
 }
 
+func (g *pendingGroup) Add(delta int) {
+	atomic.AddInt32(&g.count, int32(delta))
+	g.WaitGroup.Add(delta)
+}
+
+func (g *pendingGroup) Done() { g.Add(-1) }
+
+func (g *pendingGroup) pending() int32 { return atomic.LoadInt32(&g.count) }
+
 // Tag is a key:value pair of strings provided by user to better categorize events
 type Tag struct {
 	Key   string
@@ -308,48 +335,6 @@ func (packet *Packet) JSON() ([]byte, error) {
 	return packetJSON, nil
 }
 
-type context struct {
-	user *User
-	http *Http
-	tags map[string]string
-}
-
-func (c *context) setUser(u *User) { c.user = u }
-func (c *context) setHttp(h *Http) { c.http = h }
-func (c *context) setTags(t map[string]string) {
-	if c.tags == nil {
-		c.tags = make(map[string]string)
-	}
-	for k, v := range t {
-		c.tags[k] = v
-	}
-}
-func (c *context) clear() {
-	c.user = nil
-	c.http = nil
-	c.tags = nil
-}
-
-// Return a list of interfaces to be used in appending with the rest
-func (c *context) interfaces() []Interface {
-	len, i := 0, 0
-	if c.user != nil {
-		len++
-	}
-	if c.http != nil {
-		len++
-	}
-	interfaces := make([]Interface, len)
-	if c.user != nil {
-		interfaces[i] = c.user
-		i++
-	}
-	if c.http != nil {
-		interfaces[i] = c.http
-	}
-	return interfaces
-}
-
 // MaxQueueBuffer the maximum number of packets that will be buffered waiting to be delivered.
 // Packets will be dropped if the buffer is full. Used by NewClient.
 var MaxQueueBuffer = 100
@@ -358,6 +343,9 @@ func SetMaxQueueBuffer(maxCount int) {
 	MaxQueueBuffer = maxCount
 }
 
+// DefaultWorkers the number of goroutines concurrently delivering packets. Used by NewClient.
+var DefaultWorkers = 1
+
 func newTransport() Transport {
 	t := &HTTPTransport{}
 	rootCAs, err := gocertifi.CACerts()
@@ -379,9 +367,13 @@ func newClient(tags map[string]string) *Client {
 	client := &Client{
 		Transport:  newTransport(),
 		Tags:       tags,
-		context:    &context{},
+		context:    NewScope(),
 		sampleRate: 1.0,
 		queue:      make(chan *outgoingPacket, MaxQueueBuffer),
+		workers:    DefaultWorkers,
+
+		scrubber:       defaultScrubber,
+		sendDefaultPII: true,
 	}
 	err := client.SetDSN(os.Getenv("SENTRY_DSN"))
 
@@ -426,11 +418,21 @@ type Client struct {
 	// DropHandler is called when a packet is dropped because the buffer is full.
 	DropHandler func(*Packet)
 
+	eventProcessors []EventProcessor
+	beforeSend      EventProcessor
+
+	captureGoroutines bool
+
+	scrubber       *Scrubber
+	sendDefaultPII bool
+
 	// Context that will get appending to all packets
-	context *context
+	context *Scope
 
 	mu          sync.RWMutex
 	url         string
+	envelopeURL string
+	endpoint    Endpoint
 	projectID   string
 	authHeader  string
 	release     string
@@ -443,13 +445,22 @@ type Client struct {
 	includePaths       []string
 	ignoreErrorsRegexp *regexp.Regexp
 	queue              chan *outgoingPacket
+	spool              *Spool
+
+	// Guards closing the queue, so that no packet is sent to a closed channel
+	queueMu sync.RWMutex
+	closed  bool
+
+	// Number of background workers wanted and currently running, guarded by mu
+	workers int
+	running int
 
 	// A WaitGroup to keep track of all currently in-progress captures
 	// This is intended to be used with Client.Wait() to assure that
 	// all messages have been transported before exiting the process.
-	wg sync.WaitGroup
+	wg pendingGroup
 
-	// A Once to track only starting up the background worker once
+	// A Once to track only starting up the background workers once
 	start sync.Once
 }
 
@@ -503,15 +514,19 @@ func (client *Client) SetDSN(dsn string) error {
 	secretKey, hasSecretKey := uri.User.Password()
 	uri.User = nil
 
+	var basePath string
 	if idx := strings.LastIndex(uri.Path, "/"); idx != -1 {
 		client.projectID = uri.Path[idx+1:]
-		uri.Path = uri.Path[:idx+1] + "api/" + client.projectID + "/store/"
+		basePath = uri.Path[:idx+1] + "api/" + client.projectID
 	}
 	if client.projectID == "" {
 		return ErrMissingProjectID
 	}
 
+	uri.Path = basePath + "/store/"
 	client.url = uri.String()
+	uri.Path = basePath + "/envelope/"
+	client.envelopeURL = uri.String()
 
 	if hasSecretKey {
 		client.authHeader = fmt.Sprintf("Sentry sentry_version=4, sentry_key=%s, sentry_secret=%s", publicKey, secretKey)
@@ -583,16 +598,97 @@ func SetSampleRate(rate float32) error { return DefaultClient.SetSampleRate(rate
 // SetDebug sets the "debug" config on the default *Client
 func SetDebug(debug bool) { DefaultClient.SetDebug(debug) }
 
+// SetWorkers sets how many packets can be delivered concurrently. It can be
+// called at any time, extra workers stop after delivering their current packet.
+func (client *Client) SetWorkers(n int) error {
+	if n < 1 {
+		return ErrInvalidWorkers
+	}
+
+	client.mu.Lock()
+	client.workers = n
+	spawn := 0
+	// Workers are started lazily, only grow the pool if it is already running
+	if client.running > 0 && n > client.running {
+		spawn = n - client.running
+		client.running = n
+	}
+	client.mu.Unlock()
+
+	for i := 0; i < spawn; i++ {
+		go client.worker()
+	}
+	return nil
+}
+
+// SetWorkers sets how many packets the default *Client can deliver concurrently
+func SetWorkers(n int) error { return DefaultClient.SetWorkers(n) }
+
+func (client *Client) startWorkers() {
+	client.mu.Lock()
+	n := client.workers
+	if n < 1 {
+		n = 1
+	}
+	client.running = n
+	client.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		go client.worker()
+	}
+}
+
+// retireWorker reports whether the calling worker should stop because the pool shrank
+func (client *Client) retireWorker() bool {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.running > client.workers && client.running > 1 {
+		client.running--
+		return true
+	}
+	return false
+}
+
 func (client *Client) worker() {
 	for outgoingPacket := range client.queue {
 
 		client.mu.RLock()
-		url, authHeader := client.url, client.authHeader
+		spool := client.spool
 		client.mu.RUnlock()
 
-		outgoingPacket.ch <- client.Transport.Send(url, authHeader, outgoingPacket.packet)
+		err := client.send(outgoingPacket.packet)
+		if err == nil && spool != nil && outgoingPacket.spoolFile != "" {
+			spool.remove(outgoingPacket.spoolFile)
+		}
+
+		outgoingPacket.ch <- err
 		client.wg.Done()
+
+		if client.retireWorker() {
+			return
+		}
+	}
+}
+
+// send delivers the packet through the transport, using the configured endpoint
+func (client *Client) send(packet *Packet) error {
+	client.mu.RLock()
+	url, envelopeURL, authHeader, endpoint := client.url, client.envelopeURL, client.authHeader, client.endpoint
+	client.mu.RUnlock()
+
+	if endpoint == EndpointEnvelope {
+		if transport, ok := client.Transport.(EnvelopeTransport); ok {
+			envelope, err := NewPacketEnvelope(packet)
+			if err != nil {
+				return err
+			}
+			return transport.SendEnvelope(envelopeURL, authHeader, envelope)
+		}
+		debugLogger.Println("transport does not support envelopes, falling back to the store endpoint")
 	}
+
+	return client.Transport.Send(url, authHeader, packet)
 }
 
 // Capture asynchronously delivers a packet to the Sentry server. It is a no-op
@@ -620,6 +716,17 @@ func (client *Client) Capture(packet *Packet, captureTags map[string]string) (ev
 		return
 	}
 
+	if client.isClosed() {
+		ch <- ErrClientClosed
+		return
+	}
+
+	// Don't even queue the packet while the server asked us to back off
+	if limiter, ok := client.Transport.(RateLimitedTransport); ok && limiter.RateLimited(categoryError) {
+		ch <- ErrRateLimited
+		return
+	}
+
 	// Keep track of all running Captures so that we can wait for them all to finish
 	// *Must* call client.wg.Done() on any path that indicates that an event was
 	// finished being acted upon, whether success or failure
@@ -628,14 +735,15 @@ func (client *Client) Capture(packet *Packet, captureTags map[string]string) (ev
 	// Merge capture tags and client tags
 	packet.AddTags(captureTags)
 	packet.AddTags(client.Tags)
+	packet.AddTags(client.context.snapshotTags())
 
 	// Initialize any required packet fields
 	client.mu.RLock()
-	packet.AddTags(client.context.tags)
 	projectID := client.projectID
 	release := client.release
 	environment := client.environment
 	defaultLoggerName := client.defaultLoggerName
+	spool := client.spool
 	client.mu.RUnlock()
 
 	// set the global logger name on the packet if we must
@@ -663,28 +771,71 @@ func (client *Client) Capture(packet *Packet, captureTags map[string]string) (ev
 		packet.Environment = environment
 	}
 
-	outgoingPacket := &outgoingPacket{packet, ch}
+	// Mask sensitive values before event processors see the packet
+	client.scrubPacket(packet)
 
-	// Lazily start background worker until we
-	// do our first write into the queue.
-	client.start.Do(func() {
-		go client.worker()
-	})
+	// Give event processors a chance to mutate or drop the packet
+	if packet = client.processPacket(packet); packet == nil {
+		ch <- ErrPacketFiltered
+		client.wg.Done()
+		return "", ch
+	}
 
-	select {
-	case client.queue <- outgoingPacket:
-	default:
+	outgoingPacket := &outgoingPacket{packet: packet, ch: ch}
+
+	// Persist the packet first, so that it survives a full queue, network outage or crash
+	if spool != nil {
+		if outgoingPacket.spoolFile, err = spool.store(packet); err != nil {
+			debugLogger.Println("failed to spool packet:", err)
+		}
+	}
+
+	switch err := client.enqueue(outgoingPacket); err {
+	case nil:
+	case ErrPacketDropped:
 		// Send would block, drop the packet
 		if client.DropHandler != nil {
 			client.DropHandler(packet)
 		}
-		ch <- ErrPacketDropped
+		ch <- err
 		client.wg.Done()
+	default:
+		ch <- err
+		client.wg.Done()
+		return "", ch
 	}
 
 	return packet.EventID, ch
 }
 
+// enqueue hands the packet over to the background worker without blocking.
+// It returns ErrPacketDropped when the queue is full and ErrClientClosed after Close.
+func (client *Client) enqueue(outgoingPacket *outgoingPacket) error {
+	client.queueMu.RLock()
+	defer client.queueMu.RUnlock()
+
+	if client.closed {
+		return ErrClientClosed
+	}
+
+	// Lazily start background workers until we
+	// do our first write into the queue.
+	client.start.Do(client.startWorkers)
+
+	select {
+	case client.queue <- outgoingPacket:
+		return nil
+	default:
+		return ErrPacketDropped
+	}
+}
+
+func (client *Client) isClosed() bool {
+	client.queueMu.RLock()
+	defer client.queueMu.RUnlock()
+	return client.closed
+}
+
 // Capture asynchronously delivers a packet to the Sentry server with the default *Client.
 // It is a no-op when client is nil. A channel is provided if it is important to check for a
 // send's success.
@@ -702,7 +853,7 @@ func (client *Client) CaptureMessage(message string, tags map[string]string, int
 		return ""
 	}
 
-	packet := NewPacket(message, append(append(interfaces, client.context.interfaces()...), &Message{message, nil})...)
+	packet := NewPacket(message, append(append(interfaces, client.contextInterfaces()...), &Message{message, nil})...)
 	eventID, _ := client.Capture(packet, tags)
 
 	return eventID
@@ -713,6 +864,30 @@ func CaptureMessage(message string, tags map[string]string, interfaces ...Interf
 	return DefaultClient.CaptureMessage(message, tags, interfaces...)
 }
 
+// CaptureMessageCtx is identical to CaptureMessage, except that the scope carried by ctx, if any,
+// is merged into the packet.
+func (client *Client) CaptureMessageCtx(ctx context.Context, message string, tags map[string]string, interfaces ...Interface) string {
+	if client == nil {
+		return ""
+	}
+
+	if client.shouldExcludeErr(message) {
+		return ""
+	}
+
+	packet := NewPacket(message, append(append(interfaces, client.scopeInterfaces(ctx)...), &Message{message, nil})...)
+	ScopeFromContext(ctx).applyTo(packet)
+	eventID, _ := client.Capture(packet, tags)
+
+	return eventID
+}
+
+// CaptureMessageCtx formats and delivers a string message to the Sentry server with the default *Client,
+// merging the scope carried by ctx into the packet.
+func CaptureMessageCtx(ctx context.Context, message string, tags map[string]string, interfaces ...Interface) string {
+	return DefaultClient.CaptureMessageCtx(ctx, message, tags, interfaces...)
+}
+
 // CaptureMessageAndWait is identical to CaptureMessage except it blocks and waits for the message to be sent.
 func (client *Client) CaptureMessageAndWait(message string, tags map[string]string, interfaces ...Interface) string {
 	if client == nil {
@@ -723,7 +898,7 @@ func (client *Client) CaptureMessageAndWait(message string, tags map[string]stri
 		return ""
 	}
 
-	packet := NewPacket(message, append(append(interfaces, client.context.interfaces()...), &Message{message, nil})...)
+	packet := NewPacket(message, append(append(interfaces, client.contextInterfaces()...), &Message{message, nil})...)
 	eventID, ch := client.Capture(packet, tags)
 	if eventID != "" {
 		<-ch
@@ -737,6 +912,21 @@ func CaptureMessageAndWait(message string, tags map[string]string, interfaces ..
 	return DefaultClient.CaptureMessageAndWait(message, tags, interfaces...)
 }
 
+// newErrorPacket builds the packet reporting err, with one exception per layer of the
+// error chain, and the extra, tags, fingerprint, level and user attached to the chain
+// with the WrapWith functions. It must be called directly by the exported Capture
+// methods, the stacktrace excludes them.
+func (client *Client) newErrorPacket(err error, interfaces []Interface) *Packet {
+	cause := Cause(err)
+	exception := NewExceptionChain(err, GetOrNewStacktrace(cause, 2, 3, client.includePaths), 3, client.includePaths)
+
+	packet := NewPacketWithExtra(err.Error(), extractExtra(err), interfaces...)
+	packet.AddTags(extractTags(err))
+	applyErrorDecorations(packet, err)
+	packet.Interfaces = append(packet.Interfaces, exception)
+	return packet
+}
+
 // CaptureError formats and delivers an error to the Sentry server.
 // Adds a stacktrace to the packet, excluding the call to this method.
 func (client *Client) CaptureError(err error, tags map[string]string, interfaces ...Interface) string {
@@ -752,10 +942,7 @@ func (client *Client) CaptureError(err error, tags map[string]string, interfaces
 		return ""
 	}
 
-	extra := extractExtra(err)
-	cause := Cause(err)
-
-	packet := NewPacketWithExtra(err.Error(), extra, append(append(interfaces, client.context.interfaces()...), NewException(cause, GetOrNewStacktrace(cause, 1, 3, client.includePaths)))...)
+	packet := client.newErrorPacket(err, append(interfaces, client.contextInterfaces()...))
 	eventID, _ := client.Capture(packet, tags)
 
 	return eventID
@@ -767,6 +954,34 @@ func CaptureError(err error, tags map[string]string, interfaces ...Interface) st
 	return DefaultClient.CaptureError(err, tags, interfaces...)
 }
 
+// CaptureErrorCtx is identical to CaptureError, except that the scope carried by ctx, if any,
+// is merged into the packet.
+func (client *Client) CaptureErrorCtx(ctx context.Context, err error, tags map[string]string, interfaces ...Interface) string {
+	if client == nil {
+		return ""
+	}
+
+	if err == nil {
+		return ""
+	}
+
+	if client.shouldExcludeErr(err.Error()) {
+		return ""
+	}
+
+	packet := client.newErrorPacket(err, append(interfaces, client.scopeInterfaces(ctx)...))
+	ScopeFromContext(ctx).applyTo(packet)
+	eventID, _ := client.Capture(packet, tags)
+
+	return eventID
+}
+
+// CaptureErrorCtx formats and delivers an error to the Sentry server using the default *Client,
+// merging the scope carried by ctx into the packet.
+func CaptureErrorCtx(ctx context.Context, err error, tags map[string]string, interfaces ...Interface) string {
+	return DefaultClient.CaptureErrorCtx(ctx, err, tags, interfaces...)
+}
+
 // CaptureErrorAndWait is identical to CaptureError, except it blocks and assures that the event was sent
 func (client *Client) CaptureErrorAndWait(err error, tags map[string]string, interfaces ...Interface) string {
 	if client == nil {
@@ -777,10 +992,7 @@ func (client *Client) CaptureErrorAndWait(err error, tags map[string]string, int
 		return ""
 	}
 
-	extra := extractExtra(err)
-	cause := Cause(err)
-
-	packet := NewPacketWithExtra(err.Error(), extra, append(append(interfaces, client.context.interfaces()...), NewException(cause, GetOrNewStacktrace(cause, 1, 3, client.includePaths)))...)
+	packet := client.newErrorPacket(err, append(interfaces, client.contextInterfaces()...))
 	eventID, ch := client.Capture(packet, tags)
 	if eventID != "" {
 		<-ch
@@ -811,13 +1023,13 @@ func (client *Client) CapturePanic(f func(), tags map[string]string, interfaces
 			if client.shouldExcludeErr(rval.Error()) {
 				return
 			}
-			packet = NewPacket(rval.Error(), append(append(interfaces, client.context.interfaces()...), NewException(rval, NewStacktrace(2, 3, client.includePaths)))...)
+			packet = NewPacket(rval.Error(), append(append(append(interfaces, client.contextInterfaces()...), client.panicInterfaces()...), NewException(rval, NewStacktrace(2, 3, client.includePaths)))...)
 		default:
 			rvalStr := fmt.Sprint(rval)
 			if client.shouldExcludeErr(rvalStr) {
 				return
 			}
-			packet = NewPacket(rvalStr, append(append(interfaces, client.context.interfaces()...), NewException(errors.New(rvalStr), NewStacktrace(2, 3, client.includePaths)))...)
+			packet = NewPacket(rvalStr, append(append(append(interfaces, client.contextInterfaces()...), client.panicInterfaces()...), NewException(errors.New(rvalStr), NewStacktrace(2, 3, client.includePaths)))...)
 		}
 
 		errorID, _ = client.Capture(packet, tags)
@@ -849,13 +1061,13 @@ func (client *Client) CapturePanicAndWait(f func(), tags map[string]string, inte
 			if client.shouldExcludeErr(rval.Error()) {
 				return
 			}
-			packet = NewPacket(rval.Error(), append(append(interfaces, client.context.interfaces()...), NewException(rval, NewStacktrace(2, 3, client.includePaths)))...)
+			packet = NewPacket(rval.Error(), append(append(append(interfaces, client.contextInterfaces()...), client.panicInterfaces()...), NewException(rval, NewStacktrace(2, 3, client.includePaths)))...)
 		default:
 			rvalStr := fmt.Sprint(rval)
 			if client.shouldExcludeErr(rvalStr) {
 				return
 			}
-			packet = NewPacket(rvalStr, append(append(interfaces, client.context.interfaces()...), NewException(errors.New(rvalStr), NewStacktrace(2, 3, client.includePaths)))...)
+			packet = NewPacket(rvalStr, append(append(append(interfaces, client.contextInterfaces()...), client.panicInterfaces()...), NewException(errors.New(rvalStr), NewStacktrace(2, 3, client.includePaths)))...)
 		}
 
 		var ch chan error
@@ -874,19 +1086,79 @@ func CapturePanicAndWait(f func(), tags map[string]string, interfaces ...Interfa
 	return DefaultClient.CapturePanicAndWait(f, tags, interfaces...)
 }
 
-// Close given clients event queue
+// Close given clients event queue. Packets still waiting in the queue are abandoned,
+// and their channels receive ErrClientClosed, as do later Capture calls. Packets which
+// are being sent are not interrupted. Use Flush beforehand to give them a chance to be
+// delivered. Calling Close more than once is a no-op.
 func (client *Client) Close() {
-	close(client.queue)
+	client.CloseAndCount()
+}
+
+// CloseAndCount closes the client like Close does, and returns the number of abandoned packets
+func (client *Client) CloseAndCount() int {
+	client.queueMu.Lock()
+	defer client.queueMu.Unlock()
+
+	if client.closed {
+		return 0
+	}
+	client.closed = true
+
+	abandoned := 0
+	for {
+		select {
+		case outgoingPacket := <-client.queue:
+			outgoingPacket.ch <- ErrClientClosed
+			client.wg.Done()
+			abandoned++
+		default:
+			close(client.queue)
+			if abandoned > 0 {
+				debugLogger.Printf("abandoned %d queued packets on close", abandoned)
+			}
+			return abandoned
+		}
+	}
 }
 
 // Close defaults client event queue
 func Close() { DefaultClient.Close() }
 
+// CloseAndCount closes the default *Client and returns the number of abandoned packets
+func CloseAndCount() int { return DefaultClient.CloseAndCount() }
+
 // Wait blocks and waits for all events to finish being sent to Sentry server
 func (client *Client) Wait() {
 	client.wg.Wait()
 }
 
+// Flush waits for all events to finish being sent to Sentry server, but no longer
+// than timeout. It returns false if some events were still pending at the deadline.
+func (client *Client) Flush(timeout time.Duration) bool {
+	if client.wg.pending() == 0 {
+		return true
+	}
+
+	done := make(chan struct{})
+	go func() {
+		client.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Flush waits for all events of the default client to be sent, but no longer than timeout
+func Flush(timeout time.Duration) bool { return DefaultClient.Flush(timeout) }
+
 // Wait blocks and waits for all events to finish being sent to Sentry server
 func Wait() { DefaultClient.Wait() }
 
@@ -945,32 +1217,32 @@ func (client *Client) SetIncludePaths(p []string) {
 // SetIncludePaths updates includePaths config on default client
 func SetIncludePaths(p []string) { DefaultClient.SetIncludePaths(p) }
 
+// contextInterfaces returns the interfaces of the client's context
+func (client *Client) contextInterfaces() []Interface {
+	if client == nil {
+		return nil
+	}
+	return client.context.interfaces()
+}
+
 // SetUserContext updates User of Context interface on given client
 func (client *Client) SetUserContext(u *User) {
-	client.mu.Lock()
-	defer client.mu.Unlock()
-	client.context.setUser(u)
+	client.context.SetUser(u)
 }
 
 // SetHttpContext updates Http of Context interface on given client
 func (client *Client) SetHttpContext(h *Http) {
-	client.mu.Lock()
-	defer client.mu.Unlock()
-	client.context.setHttp(h)
+	client.context.SetHttp(h)
 }
 
 // SetTagsContext updates Tags of Context interface on given client
 func (client *Client) SetTagsContext(t map[string]string) {
-	client.mu.Lock()
-	defer client.mu.Unlock()
-	client.context.setTags(t)
+	client.context.SetTags(t)
 }
 
-// ClearContext clears Context interface on given client by removing tags, user and request information
+// ClearContext clears Context interface on given client by removing tags, user, request information and breadcrumbs
 func (client *Client) ClearContext() {
-	client.mu.Lock()
-	defer client.mu.Unlock()
-	client.context.clear()
+	client.context.Clear()
 }
 
 // SetUserContext updates User of Context interface on default client
@@ -982,13 +1254,31 @@ func SetHttpContext(h *Http) { DefaultClient.SetHttpContext(h) }
 // SetTagsContext updates Tags of Context interface on default client
 func SetTagsContext(t map[string]string) { DefaultClient.SetTagsContext(t) }
 
-// ClearContext clears Context interface on default client by removing tags, user and request information
+// ClearContext clears Context interface on default client by removing tags, user, request information and breadcrumbs
 func ClearContext() { DefaultClient.ClearContext() }
 
 // HTTPTransport is the default transport, delivering packets to Sentry via the
 // HTTP API.
 type HTTPTransport struct {
 	*http.Client
+
+	// RetryPolicy controls retries of failed deliveries. A nil policy makes a single attempt.
+	RetryPolicy *RetryPolicy
+
+	mu     sync.RWMutex
+	limits rateLimits
+}
+
+// SetRetryPolicy updates the retry policy used by subsequent Send calls
+func (t *HTTPTransport) SetRetryPolicy(policy *RetryPolicy) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.RetryPolicy = policy
+}
+
+// RateLimited reports whether the server asked to stop sending the given category for now
+func (t *HTTPTransport) RateLimited(category string) bool {
+	return t.limits.isRateLimited(category, time.Now())
 }
 
 // Send uses HTTPTransport to send a Packet to configured Sentry's DSN endpoint
@@ -997,13 +1287,47 @@ func (t *HTTPTransport) Send(url, authHeader string, packet *Packet) error {
 		return nil
 	}
 
+	if t.RateLimited(categoryError) {
+		return ErrRateLimited
+	}
+
 	body, contentType, err := serializedPacket(packet)
 	if err != nil {
 		return fmt.Errorf("raven: error serializing packet: %v", err)
 	}
-	req, err := http.NewRequest("POST", url, body)
+
+	return t.deliver(url, authHeader, contentType, body, []string{categoryError})
+}
+
+// deliver posts the body, retrying according to the retry policy until the server
+// rate limits one of the categories of the payload
+func (t *HTTPTransport) deliver(url, authHeader, contentType string, body []byte, categories []string) error {
+	t.mu.RLock()
+	policy := t.RetryPolicy
+	t.mu.RUnlock()
+
+	for attempt := 1; ; attempt++ {
+		retryable, err := t.send(url, authHeader, contentType, body, policy)
+		if err == nil || !retryable || attempt >= policy.Attempts() {
+			return err
+		}
+		for _, category := range categories {
+			if t.RateLimited(category) {
+				return ErrRateLimited
+			}
+		}
+
+		backoff := policy.Backoff(attempt)
+		debugLogger.Printf("Attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		time.Sleep(backoff)
+	}
+}
+
+// send performs a single delivery attempt and reports whether a failure may be retried
+func (t *HTTPTransport) send(url, authHeader, contentType string, body []byte, policy *RetryPolicy) (bool, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("raven: can't create new request: %v", err)
+		return false, fmt.Errorf("raven: can't create new request: %v", err)
 	}
 	req.Header.Set("X-Sentry-Auth", authHeader)
 	req.Header.Set("User-Agent", userAgent)
@@ -1011,7 +1335,7 @@ func (t *HTTPTransport) Send(url, authHeader string, packet *Packet) error {
 
 	res, err := t.Do(req)
 	if err != nil {
-		return err
+		return true, err
 	}
 
 	// Response body needs to be drained and closed in order for TCP connection to stay opened (via keep-alive) and reused
@@ -1025,13 +1349,18 @@ func (t *HTTPTransport) Send(url, authHeader string, packet *Packet) error {
 		debugLogger.Println("Error while closing response body", err)
 	}
 
+	t.limits.update(res, time.Now())
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return false, ErrRateLimited
+	}
 	if res.StatusCode != 200 {
-		return fmt.Errorf("raven: got http status %d - x-sentry-error: %s", res.StatusCode, res.Header.Get("X-Sentry-Error"))
+		return policy.IsRetryableStatus(res.StatusCode), fmt.Errorf("raven: got http status %d - x-sentry-error: %s", res.StatusCode, res.Header.Get("X-Sentry-Error"))
 	}
-	return nil
+	return false, nil
 }
 
-func serializedPacket(packet *Packet) (io.Reader, string, error) {
+func serializedPacket(packet *Packet) ([]byte, string, error) {
 	packetJSON, err := packet.JSON()
 	if err != nil {
 		return nil, "", fmt.Errorf("raven: error marshaling packet %+v to JSON: %v", packet, err)
@@ -1054,9 +1383,9 @@ func serializedPacket(packet *Packet) (io.Reader, string, error) {
 		if err != nil {
 			debugLogger.Println("Error while closing b64 encoder in packet serializer", err)
 		}
-		return buf, "application/octet-stream", nil
+		return buf.Bytes(), "application/octet-stream", nil
 	}
-	return bytes.NewReader(packetJSON), "application/json", nil
+	return packetJSON, "application/json", nil
 }
 
 var hostname string
@@ -1066,14 +1395,25 @@ func init() {
 }
 
 // Cause returns the underlying cause of the error, if possible.
-// An error value has a cause if it implements the following
-// interface:
+// An error value has a cause if it implements one of the following
+// interfaces:
 //
 //     type causer interface {
 //            Cause() error
 //     }
 //
-// If the error does not implement Cause, the original error will
+//     type wrapper interface {
+//            Unwrap() error
+//     }
+//
+//     type multiWrapper interface {
+//            Unwrap() []error
+//     }
+//
+// For multi-errors, such as the ones returned by errors.Join, the first
+// error is followed.
+//
+// If the error does not implement any of them, the original error will
 // be returned.
 //
 // If the cause of the error is nil, then the original
@@ -1084,22 +1424,12 @@ func init() {
 //
 // Will return the deepest cause which is not nil.
 func Cause(err error) error {
-	type causer interface {
-		Cause() error
-	}
-
 	for err != nil {
-		cause, ok := err.(causer)
-		if !ok {
-			break
-		}
-
-		if _cause := cause.Cause(); _cause != nil {
-			err = _cause
-		} else {
+		cause := unwrap(err)
+		if cause == nil {
 			break
 		}
-
+		err = cause
 	}
 	return err
 }
@@ -20,7 +20,7 @@ func TestShouldExcludeErr(t *testing.T) {
 	client := &Client{
 		Transport: newTransport(),
 		Tags:      nil,
-		context:   &context{},
+		context:   &Scope{},
 		queue:     make(chan *outgoingPacket, MaxQueueBuffer),
 	}
 
@@ -148,6 +148,9 @@ func TestSetDSN(t *testing.T) {
 	if client.url != "https://example.com/sentry/api/1/store/" {
 		t.Error("incorrect url:", client.url)
 	}
+	if client.envelopeURL != "https://example.com/sentry/api/1/envelope/" {
+		t.Error("incorrect envelope url:", client.envelopeURL)
+	}
 	if client.projectID != "1" {
 		t.Error("incorrect projectID:", client.projectID)
 	}
@@ -362,3 +365,156 @@ func TestNewPacketWithExtraSetsDefault(t *testing.T) {
 		}
 	}
 }
+
+type blockingTransport struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingTransport() *blockingTransport {
+	return &blockingTransport{started: make(chan struct{}, MaxQueueBuffer), release: make(chan struct{})}
+}
+
+func (t *blockingTransport) Send(url, authHeader string, packet *Packet) error {
+	t.started <- struct{}{}
+	<-t.release
+	return nil
+}
+
+func newTestClient(transport Transport) *Client {
+	return &Client{
+		Transport:  transport,
+		context:    &Scope{},
+		sampleRate: 1.0,
+		queue:      make(chan *outgoingPacket, MaxQueueBuffer),
+
+		scrubber:       defaultScrubber,
+		sendDefaultPII: true,
+	}
+}
+
+func TestCaptureAfterClose(t *testing.T) {
+	client := newTestClient(newBlockingTransport())
+	client.Close()
+
+	eventID, ch := client.Capture(NewPacket("closed"), nil)
+	if eventID != "" {
+		t.Error("expected empty eventID:", eventID)
+	}
+	if err := <-ch; err != ErrClientClosed {
+		t.Errorf("expected ErrClientClosed, got %v", err)
+	}
+}
+
+func TestCloseAbandonsQueuedPackets(t *testing.T) {
+	transport := newBlockingTransport()
+	client := newTestClient(transport)
+
+	_, inFlight := client.Capture(NewPacket("in flight"), nil)
+	<-transport.started
+
+	var queued []chan error
+	for i := 0; i < 3; i++ {
+		_, ch := client.Capture(NewPacket("queued"), nil)
+		queued = append(queued, ch)
+	}
+
+	if abandoned := client.CloseAndCount(); abandoned != 3 {
+		t.Errorf("incorrect number of abandoned packets: got %d, want 3", abandoned)
+	}
+	if abandoned := client.CloseAndCount(); abandoned != 0 {
+		t.Errorf("expected second Close to be a no-op, got %d abandoned packets", abandoned)
+	}
+	for _, ch := range queued {
+		if err := <-ch; err != ErrClientClosed {
+			t.Errorf("expected ErrClientClosed, got %v", err)
+		}
+	}
+
+	close(transport.release)
+	if err := <-inFlight; err != nil {
+		t.Errorf("expected in flight packet to be delivered, got %v", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	transport := newBlockingTransport()
+	client := newTestClient(transport)
+
+	client.Capture(NewPacket("pending"), nil)
+	<-transport.started
+
+	if client.Flush(10 * time.Millisecond) {
+		t.Error("expected Flush to time out while a packet is pending")
+	}
+
+	close(transport.release)
+	if !client.Flush(time.Second) {
+		t.Error("expected Flush to succeed once packets are delivered")
+	}
+}
+
+func TestFlushWithoutPendingPackets(t *testing.T) {
+	client := newTestClient(newBlockingTransport())
+	if !client.Flush(0) {
+		t.Error("expected Flush to succeed right away when no packet is pending")
+	}
+}
+
+// Close keeps the signature it always had, so that existing callers storing it as a func() still build
+var _ interface{ Close() } = &Client{}
+
+func TestSetWorkersInvalid(t *testing.T) {
+	client := newTestClient(newBlockingTransport())
+	if err := client.SetWorkers(0); err != ErrInvalidWorkers {
+		t.Errorf("expected ErrInvalidWorkers, got %v", err)
+	}
+}
+
+func TestWorkersSendConcurrently(t *testing.T) {
+	transport := newBlockingTransport()
+	client := newTestClient(transport)
+	if err := client.SetWorkers(3); err != nil {
+		t.Fatal(err)
+	}
+
+	var chs []chan error
+	for i := 0; i < 3; i++ {
+		_, ch := client.Capture(NewPacket("concurrent"), nil)
+		chs = append(chs, ch)
+	}
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-transport.started:
+		case <-time.After(time.Second):
+			t.Fatalf("expected 3 packets in flight, got %d", i)
+		}
+	}
+
+	close(transport.release)
+	for _, ch := range chs {
+		if err := <-ch; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestSetWorkersGrowsRunningPool(t *testing.T) {
+	transport := newBlockingTransport()
+	client := newTestClient(transport)
+
+	client.Capture(NewPacket("first"), nil)
+	<-transport.started
+
+	client.SetWorkers(2)
+	client.Capture(NewPacket("second"), nil)
+	select {
+	case <-transport.started:
+	case <-time.After(time.Second):
+		t.Fatal("expected the new worker to pick up the second packet")
+	}
+
+	close(transport.release)
+	client.Wait()
+}
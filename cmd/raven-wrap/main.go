@@ -0,0 +1,197 @@
+// Command raven-wrap runs a Go program and reports to Sentry the panics and fatal
+// runtime errors it crashes with, which can't be recovered from within the program.
+//
+// The standard streams are passed through, the stderr output is watched for a crash.
+// The client is configured from the SENTRY_DSN, SENTRY_RELEASE and SENTRY_ENVIRONMENT
+// environment variables, and raven-wrap exits with the status of the program.
+//
+// Usage:
+//
+//	raven-wrap [-timeout 10s] program [arguments...]
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/getsentry/raven-go"
+)
+
+// maxCrashOutput caps how much of the crash output is kept for parsing
+const maxCrashOutput = 8 << 20
+
+// Prefixes of the first line printed by the Go runtime on a crash
+var crashPrefixes = []string{"panic: ", "fatal error: "}
+
+// crashDetector records the output of the program from the line where a crash starts
+type crashDetector struct {
+	line     []byte // start of the current line, while it may still start a crash
+	skipLine bool   // the current line doesn't start a crash
+	output   []byte
+}
+
+func (d *crashDetector) Write(p []byte) (int, error) {
+	if d.output != nil {
+		d.record(p)
+		return len(p), nil
+	}
+
+	for i, b := range p {
+		switch {
+		case b == '\n':
+			d.line, d.skipLine = d.line[:0], false
+		case d.skipLine:
+		default:
+			d.line = append(d.line, b)
+			if isCrashLine(d.line) {
+				d.output = append([]byte{}, d.line...)
+				d.record(p[i+1:])
+				return len(p), nil
+			}
+			d.skipLine = !mayBeCrashLine(d.line)
+		}
+	}
+	return len(p), nil
+}
+
+func (d *crashDetector) record(p []byte) {
+	if room := maxCrashOutput - len(d.output); len(p) > room {
+		p = p[:room]
+	}
+	d.output = append(d.output, p...)
+}
+
+func isCrashLine(line []byte) bool {
+	for _, prefix := range crashPrefixes {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func mayBeCrashLine(line []byte) bool {
+	for _, prefix := range crashPrefixes {
+		if bytes.HasPrefix([]byte(prefix), line) {
+			return true
+		}
+	}
+	return false
+}
+
+// crashPacket builds the packet reporting the crash output, or returns nil if there is
+// none or the program exited successfully, having merely printed a crash-like line
+func crashPacket(output []byte, status int) *raven.Packet {
+	if len(output) == 0 || status == 0 {
+		return nil
+	}
+
+	firstLine := string(output)
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+
+	exception := &raven.Exception{Value: firstLine}
+	for _, prefix := range crashPrefixes {
+		if strings.HasPrefix(firstLine, prefix) {
+			exception.Type = strings.TrimSuffix(prefix, ": ")
+			exception.Value = strings.TrimPrefix(firstLine, prefix)
+		}
+	}
+	if stacktraces, err := raven.ParseGoTrace(output); err == nil {
+		exception.Stacktrace = stacktraces[0]
+	}
+
+	packet := raven.NewPacket(firstLine, exception)
+	packet.Level = raven.FATAL
+	return packet
+}
+
+// exitStatus returns the status to exit with for the error returned by running the program
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return 127
+	}
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return 1
+	}
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 10*time.Second, "how long to wait for the crash report to be sent")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-timeout 10s] program [arguments...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	detector := &crashDetector{}
+	cmd := exec.Command(flag.Arg(0), flag.Args()[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, detector)
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, "raven-wrap:", err)
+		os.Exit(127)
+	}
+
+	// Let the program handle the signals sent to us
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err := cmd.Wait()
+	signal.Stop(signals)
+	status := exitStatus(err)
+
+	if packet := crashPacket(detector.output, status); packet != nil {
+		packet.Extra = raven.Extra{
+			"command":     strings.Join(flag.Args(), " "),
+			"exit_status": status,
+		}
+		tags := map[string]string{"program": filepath.Base(flag.Arg(0))}
+
+		client, err := raven.New(os.Getenv("SENTRY_DSN"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "raven-wrap:", err)
+		} else if eventID, ch := client.Capture(packet, tags); eventID != "" {
+			select {
+			case err := <-ch:
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "raven-wrap: failed to report crash:", err)
+				}
+			case <-time.After(*timeout):
+				fmt.Fprintln(os.Stderr, "raven-wrap: timed out reporting crash")
+			}
+		}
+	}
+
+	os.Exit(status)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/getsentry/raven-go"
+)
+
+const crashOutput = `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x48f1f6]
+
+goroutine 1 [running]:
+main.main()
+	/src/app/main.go:12 +0x16
+exit status 2
+`
+
+func TestCrashDetector(t *testing.T) {
+	detector := &crashDetector{}
+	// Split writes, the way a pipe may deliver the output
+	detector.Write([]byte("starting\nthe panic: is not at the start of this line\npan"))
+	detector.Write([]byte(crashOutput[3:20]))
+	detector.Write([]byte(crashOutput[20:]))
+
+	if string(detector.output) != crashOutput {
+		t.Errorf("incorrect output: got %q, want %q", detector.output, crashOutput)
+	}
+}
+
+func TestCrashDetectorWithoutCrash(t *testing.T) {
+	detector := &crashDetector{}
+	detector.Write([]byte("all good\nexiting\n"))
+
+	if detector.output != nil {
+		t.Errorf("expected no output to be recorded, got %q", detector.output)
+	}
+}
+
+func TestCrashPacket(t *testing.T) {
+	packet := crashPacket([]byte(crashOutput), 2)
+	if packet == nil {
+		t.Fatal("expected a packet")
+	}
+	if packet.Level != raven.FATAL {
+		t.Errorf("incorrect Level: got %q", packet.Level)
+	}
+
+	exception, ok := packet.Interfaces[0].(*raven.Exception)
+	if !ok {
+		t.Fatalf("expected an Exception, got %#v", packet.Interfaces[0])
+	}
+	if exception.Type != "panic" || exception.Value != "runtime error: invalid memory address or nil pointer dereference" {
+		t.Errorf("incorrect exception: got %s %q", exception.Type, exception.Value)
+	}
+	if exception.Stacktrace == nil || len(exception.Stacktrace.Frames) != 1 || exception.Stacktrace.Frames[0].Lineno != 12 {
+		t.Errorf("incorrect stacktrace: got %+v", exception.Stacktrace)
+	}
+}
+
+func TestCrashPacketSuccessfulExit(t *testing.T) {
+	if packet := crashPacket([]byte(crashOutput), 0); packet != nil {
+		t.Errorf("expected no packet for a program exiting successfully, got %q", packet.Message)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	if status := exitStatus(exec.Command("sh", "-c", "exit 3").Run()); status != 3 {
+		t.Errorf("incorrect status: got %d, want 3", status)
+	}
+	if status := exitStatus(exec.Command("sh", "-c", "kill -9 $$").Run()); status != 137 {
+		t.Errorf("incorrect status for a killed program: got %d, want 137", status)
+	}
+}
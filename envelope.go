@@ -0,0 +1,172 @@
+package raven
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Endpoint selects the Sentry API endpoint packets are delivered to
+type Endpoint int
+
+const (
+	// EndpointStore posts every packet on its own to the legacy /store/ endpoint
+	EndpointStore Endpoint = iota
+	// EndpointEnvelope posts packets wrapped in an envelope to the /envelope/ endpoint
+	EndpointEnvelope
+)
+
+// Envelope item types
+const (
+	EnvelopeItemEvent       = "event"
+	EnvelopeItemTransaction = "transaction"
+	EnvelopeItemSession     = "session"
+	EnvelopeItemAttachment  = "attachment"
+)
+
+// EnvelopeTransport is implemented by transports which can deliver envelopes.
+// When the client is configured with EndpointEnvelope and the transport doesn't
+// implement it, packets are sent with Send to the store endpoint.
+type EnvelopeTransport interface {
+	Transport
+	SendEnvelope(url, authHeader string, envelope *Envelope) error
+}
+
+// Envelope defines Sentry's format used to send one or more items in a single request - https://develop.sentry.dev/sdk/envelopes/
+type Envelope struct {
+	// Optional
+	EventID string
+	SentAt  time.Time
+	Header  map[string]interface{}
+
+	Items []*EnvelopeItem
+}
+
+// EnvelopeItem is a single typed payload of an Envelope
+type EnvelopeItem struct {
+	// Required
+	Type    string
+	Payload []byte
+
+	// Optional
+	Header map[string]interface{}
+}
+
+// NewEnvelope constructs an empty envelope for the given event ID
+func NewEnvelope(eventID string) *Envelope {
+	return &Envelope{EventID: eventID}
+}
+
+// NewPacketEnvelope constructs an envelope holding the packet as an event item
+func NewPacketEnvelope(packet *Packet) (*Envelope, error) {
+	packetJSON, err := packet.JSON()
+	if err != nil {
+		return nil, fmt.Errorf("raven: error marshaling packet %+v to JSON: %v", packet, err)
+	}
+
+	envelope := NewEnvelope(packet.EventID)
+	envelope.AddItem(EnvelopeItemEvent, packetJSON)
+	return envelope, nil
+}
+
+// AddItem appends a new item to the envelope and returns it, so that headers can be added
+func (e *Envelope) AddItem(itemType string, payload []byte) *EnvelopeItem {
+	item := &EnvelopeItem{Type: itemType, Payload: payload}
+	e.Items = append(e.Items, item)
+	return item
+}
+
+// Serialize encodes the envelope into its newline delimited wire format
+func (e *Envelope) Serialize() ([]byte, error) {
+	header := make(map[string]interface{}, len(e.Header)+2)
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	if e.EventID != "" {
+		header["event_id"] = e.EventID
+	}
+	sentAt := e.SentAt
+	if sentAt.IsZero() {
+		sentAt = time.Now()
+	}
+	header["sent_at"] = sentAt.UTC().Format(time.RFC3339Nano)
+
+	buf := &bytes.Buffer{}
+	if err := writeEnvelopeLine(buf, header); err != nil {
+		return nil, err
+	}
+
+	for _, item := range e.Items {
+		itemHeader := make(map[string]interface{}, len(item.Header)+2)
+		for k, v := range item.Header {
+			itemHeader[k] = v
+		}
+		itemHeader["type"] = item.Type
+		itemHeader["length"] = len(item.Payload)
+
+		if err := writeEnvelopeLine(buf, itemHeader); err != nil {
+			return nil, err
+		}
+		buf.Write(item.Payload)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeEnvelopeLine(buf *bytes.Buffer, header map[string]interface{}) error {
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("raven: error marshaling envelope header: %v", err)
+	}
+	buf.Write(headerJSON)
+	buf.WriteByte('\n')
+	return nil
+}
+
+// Rate limit category of an envelope item - https://develop.sentry.dev/sdk/rate-limiting/#definitions
+func itemCategory(itemType string) string {
+	if itemType == EnvelopeItemEvent {
+		return categoryError
+	}
+	return itemType
+}
+
+// SendEnvelope uses HTTPTransport to send an Envelope to configured Sentry's envelope endpoint.
+// Items of rate limited categories are left out.
+func (t *HTTPTransport) SendEnvelope(url, authHeader string, envelope *Envelope) error {
+	if url == "" {
+		return nil
+	}
+
+	filtered := *envelope
+	filtered.Items = nil
+	var categories []string
+	for _, item := range envelope.Items {
+		if category := itemCategory(item.Type); !t.RateLimited(category) {
+			filtered.Items = append(filtered.Items, item)
+			categories = append(categories, category)
+		}
+	}
+	if len(filtered.Items) == 0 {
+		return ErrRateLimited
+	}
+
+	body, err := filtered.Serialize()
+	if err != nil {
+		return fmt.Errorf("raven: error serializing envelope: %v", err)
+	}
+
+	return t.deliver(url, authHeader, "application/x-sentry-envelope", body, categories)
+}
+
+// SetEndpoint selects the Sentry API endpoint used by the client
+func (client *Client) SetEndpoint(endpoint Endpoint) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.endpoint = endpoint
+}
+
+// SetEndpoint selects the Sentry API endpoint used by the default *Client
+func SetEndpoint(endpoint Endpoint) { DefaultClient.SetEndpoint(endpoint) }
@@ -0,0 +1,108 @@
+package raven
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEnvelopeSerialize(t *testing.T) {
+	envelope := NewEnvelope("abc")
+	envelope.SentAt = time.Date(2000, 01, 01, 0, 0, 0, 0, time.UTC)
+	envelope.AddItem(EnvelopeItemEvent, []byte(`{"message":"foo"}`))
+	attachment := envelope.AddItem(EnvelopeItemAttachment, []byte("hello"))
+	attachment.Header = map[string]interface{}{"filename": "hello.txt"}
+
+	expected := `{"event_id":"abc","sent_at":"2000-01-01T00:00:00Z"}
+{"length":17,"type":"event"}
+{"message":"foo"}
+{"filename":"hello.txt","length":5,"type":"attachment"}
+hello
+`
+	actual, err := envelope.Serialize()
+	if err != nil {
+		t.Fatal("serialization should not fail:", err)
+	}
+	if string(actual) != expected {
+		t.Errorf("incorrect envelope; got %s, want %s", actual, expected)
+	}
+}
+
+func TestClientSendsEnvelopes(t *testing.T) {
+	var path, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	client := newTestClient(&HTTPTransport{Client: &http.Client{}})
+	if err := client.SetDSN(strings.Replace(server.URL, "http://", "http://public@", 1) + "/1"); err != nil {
+		t.Fatal(err)
+	}
+	client.SetEndpoint(EndpointEnvelope)
+
+	eventID, ch := client.Capture(NewPacket("enveloped"), nil)
+	if err := <-ch; err != nil {
+		t.Fatal("delivery should not fail:", err)
+	}
+
+	if path != "/api/1/envelope/" {
+		t.Errorf("incorrect path: %s", path)
+	}
+	if contentType != "application/x-sentry-envelope" {
+		t.Errorf("incorrect content type: %s", contentType)
+	}
+
+	lines := bytes.Split(body, []byte("\n"))
+	if len(lines) != 4 {
+		t.Fatalf("incorrect number of lines in envelope: %q", body)
+	}
+	var header, itemHeader map[string]interface{}
+	var packet Packet
+	json.Unmarshal(lines[0], &header)
+	json.Unmarshal(lines[1], &itemHeader)
+	json.Unmarshal(lines[2], &packet)
+	if header["event_id"] != eventID {
+		t.Errorf("incorrect envelope header: %v", header)
+	}
+	if itemHeader["type"] != "event" || int(itemHeader["length"].(float64)) != len(lines[2]) {
+		t.Errorf("incorrect item header: %v", itemHeader)
+	}
+	if packet.Message != "enveloped" || packet.EventID != eventID {
+		t.Errorf("incorrect packet: %+v", packet)
+	}
+}
+
+func TestClientFallsBackToStoreEndpoint(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetDSN("https://u@example.com/1")
+	client.SetEndpoint(EndpointEnvelope)
+
+	_, ch := client.Capture(NewPacket("stored"), nil)
+	if err := <-ch; err != nil {
+		t.Fatal("delivery should not fail:", err)
+	}
+	if len(transport.packets) != 1 {
+		t.Errorf("expected packet to be sent through Send, got %d packets", len(transport.packets))
+	}
+}
+
+func TestHTTPTransportDropsRateLimitedItems(t *testing.T) {
+	transport := &HTTPTransport{}
+	transport.limits.limit(categoryError, time.Now().Add(time.Minute))
+
+	envelope := NewEnvelope("abc")
+	envelope.AddItem(EnvelopeItemEvent, []byte("{}"))
+	if err := transport.SendEnvelope("http://example.com", "", envelope); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+}
@@ -45,26 +45,266 @@ type ErrWithExtra interface {
 	Cause() error
 }
 
-// Iteratively fetches all the Extra data added to an error,
-// and it's underlying errors. Extra data defined first is
-// respected, and is not overridden when extracting.
+type errWrappedWithTags struct {
+	err  error
+	tags map[string]string
+}
+
+func (ewt *errWrappedWithTags) Error() string {
+	if ewt.err == nil {
+		return ""
+	}
+
+	return ewt.err.Error()
+}
+
+func (ewt *errWrappedWithTags) Cause() error {
+	return ewt.err
+}
+
+func (ewt *errWrappedWithTags) Tags() map[string]string {
+	return ewt.tags
+}
+
+// WrapWithTags adds tags to an error before reporting to Sentry.
+// When several errors of the chain carry the same tag, the innermost one wins.
+func WrapWithTags(err error, tags map[string]string) error {
+	return &errWrappedWithTags{
+		err:  err,
+		tags: tags,
+	}
+}
+
+type errWithTags interface {
+	error
+	Tags() map[string]string
+}
+
+type errWrappedWithFingerprint struct {
+	err         error
+	fingerprint []string
+}
+
+func (ewf *errWrappedWithFingerprint) Error() string {
+	if ewf.err == nil {
+		return ""
+	}
+
+	return ewf.err.Error()
+}
+
+func (ewf *errWrappedWithFingerprint) Cause() error {
+	return ewf.err
+}
+
+func (ewf *errWrappedWithFingerprint) Fingerprint() []string {
+	return ewf.fingerprint
+}
+
+// WrapWithFingerprint sets the fingerprint used by Sentry to group an error.
+// When several errors of the chain carry a fingerprint, the outermost one wins.
+func WrapWithFingerprint(err error, fingerprint ...string) error {
+	return &errWrappedWithFingerprint{
+		err:         err,
+		fingerprint: fingerprint,
+	}
+}
+
+type errWithFingerprint interface {
+	error
+	Fingerprint() []string
+}
+
+type errWrappedWithLevel struct {
+	err   error
+	level Severity
+}
+
+func (ewl *errWrappedWithLevel) Error() string {
+	if ewl.err == nil {
+		return ""
+	}
+
+	return ewl.err.Error()
+}
+
+func (ewl *errWrappedWithLevel) Cause() error {
+	return ewl.err
+}
+
+func (ewl *errWrappedWithLevel) Level() Severity {
+	return ewl.level
+}
+
+// WrapWithLevel sets the severity an error is reported with.
+// When several errors of the chain carry a level, the outermost one wins.
+func WrapWithLevel(err error, level Severity) error {
+	return &errWrappedWithLevel{
+		err:   err,
+		level: level,
+	}
+}
+
+type errWithLevel interface {
+	error
+	Level() Severity
+}
+
+type errWrappedWithUser struct {
+	err  error
+	user *User
+}
+
+func (ewu *errWrappedWithUser) Error() string {
+	if ewu.err == nil {
+		return ""
+	}
+
+	return ewu.err.Error()
+}
+
+func (ewu *errWrappedWithUser) Cause() error {
+	return ewu.err
+}
+
+func (ewu *errWrappedWithUser) User() *User {
+	return ewu.user
+}
+
+// WrapWithUser sets the user an error is reported for, overriding the one of the client's context.
+// When several errors of the chain carry a user, the outermost one wins.
+func WrapWithUser(err error, user *User) error {
+	return &errWrappedWithUser{
+		err:  err,
+		user: user,
+	}
+}
+
+type errWithUser interface {
+	error
+	User() *User
+}
+
+// walkErrors calls fn with err and every error it wraps, outermost first,
+// following Cause(), Unwrap() and the branches of multi-errors
+func walkErrors(err error, fn func(error)) {
+	if err == nil {
+		return
+	}
+	fn(err)
+
+	if multi, ok := err.(multiWrapper); ok {
+		for _, branch := range multi.Unwrap() {
+			walkErrors(branch, fn)
+		}
+		return
+	}
+	walkErrors(unwrap(err), fn)
+}
+
+// convertsTo reports whether err converts itself to target, a pointer to one of
+// the interfaces above, through an As method like the ones errors.As relies on.
+// Contrary to errors.As it doesn't follow the chain, see walkErrors.
+func convertsTo(err error, target interface{}) bool {
+	convertible, ok := err.(interface{ As(interface{}) bool })
+	return ok && convertible.As(target)
+}
+
+// Recursively fetches all the Extra data added to an error,
+// and it's underlying errors, following Cause(), Unwrap() and the
+// branches of multi-errors such as the ones returned by errors.Join.
+// Extra data defined first is respected, and is not overridden when
+// extracting. Between branches of a multi-error, the later ones win.
+// Errors may also provide them through an As method.
 func extractExtra(err error) Extra {
 	extra := Extra{}
-
-	currentErr := err
-	for currentErr != nil {
-		if errWithExtra, ok := currentErr.(errWithJustExtra); ok {
+	walkErrors(err, func(err error) {
+		errWithExtra, ok := err.(errWithJustExtra)
+		if !ok {
+			ok = convertsTo(err, &errWithExtra) && errWithExtra != nil
+		}
+		if ok {
 			for k, v := range errWithExtra.ExtraInfo() {
 				extra[k] = v
 			}
 		}
+	})
+	return extra
+}
 
-		if errWithCause, ok := currentErr.(causer); ok {
-			currentErr = errWithCause.Cause()
-		} else {
-			currentErr = nil
+// extractTags fetches the tags added to an error and it's underlying errors,
+// with the same precedence as extractExtra
+func extractTags(err error) map[string]string {
+	tags := make(map[string]string)
+	walkErrors(err, func(err error) {
+		errWithTags, ok := err.(errWithTags)
+		if !ok {
+			ok = convertsTo(err, &errWithTags) && errWithTags != nil
 		}
-	}
+		if ok {
+			for k, v := range errWithTags.Tags() {
+				tags[k] = v
+			}
+		}
+	})
+	return tags
+}
 
-	return extra
+// applyErrorDecorations sets the fingerprint, level and user carried by the
+// error chain on the packet. The outermost error setting one wins.
+func applyErrorDecorations(packet *Packet, err error) {
+	var fingerprintSet, levelSet, userSet bool
+	walkErrors(err, func(err error) {
+		errWithFingerprint, ok := err.(errWithFingerprint)
+		if !ok {
+			ok = convertsTo(err, &errWithFingerprint) && errWithFingerprint != nil
+		}
+		if ok && !fingerprintSet && len(errWithFingerprint.Fingerprint()) > 0 {
+			packet.Fingerprint, fingerprintSet = errWithFingerprint.Fingerprint(), true
+		}
+
+		errWithLevel, ok := err.(errWithLevel)
+		if !ok {
+			ok = convertsTo(err, &errWithLevel) && errWithLevel != nil
+		}
+		if ok && !levelSet && errWithLevel.Level() != "" {
+			packet.Level, levelSet = errWithLevel.Level(), true
+		}
+
+		errWithUser, ok := err.(errWithUser)
+		if !ok {
+			ok = convertsTo(err, &errWithUser) && errWithUser != nil
+		}
+		if ok && !userSet && errWithUser.User() != nil {
+			packet.Interfaces = append(packet.Interfaces, errWithUser.User())
+			userSet = true
+		}
+	})
+}
+
+type wrapper interface {
+	Unwrap() error
+}
+
+// multiWrapper is implemented by errors wrapping several errors, such as the ones returned by errors.Join
+type multiWrapper interface {
+	Unwrap() []error
+}
+
+// unwrap returns the error wrapped by err, following either Cause() or
+// the Go 1.13 Unwrap() convention. Multi-errors unwrap to their first error.
+func unwrap(err error) error {
+	switch e := err.(type) {
+	case causer:
+		return e.Cause()
+	case wrapper:
+		return e.Unwrap()
+	case multiWrapper:
+		for _, branch := range e.Unwrap() {
+			if branch != nil {
+				return branch
+			}
+		}
+	}
+	return nil
 }
@@ -1,8 +1,10 @@
 package raven
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -163,3 +165,144 @@ func TestErrWrappedWithExtraWithNilError(t *testing.T) {
 		t.Errorf("Expected empty string got %s", errString)
 	}
 }
+
+type joinedErr []error
+
+func (e joinedErr) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e joinedErr) Unwrap() []error { return e }
+
+// convertibleErr provides its extra and level only through As, like errors.As allows
+type convertibleErr struct{}
+
+func (e *convertibleErr) Error() string { return "convertible" }
+
+func (e *convertibleErr) As(target interface{}) bool {
+	switch target := target.(type) {
+	case *errWithJustExtra:
+		*target = &errorWithJustExtra{}
+		return true
+	case *errWithLevel:
+		*target = WrapWithLevel(e, WARNING).(errWithLevel)
+		return true
+	}
+	return false
+}
+
+func TestCauseFollowsUnwrap(t *testing.T) {
+	baseErr := errors.New("this is bad")
+
+	testCases := []error{
+		&wrappedErr{msg: "outer", err: baseErr},
+		WrapWithExtra(&wrappedErr{msg: "outer", err: baseErr}, nil),
+		joinedErr{&wrappedErr{msg: "first", err: baseErr}, errors.New("second")},
+	}
+	for i, err := range testCases {
+		if cause := Cause(err); cause != baseErr {
+			t.Errorf("Case [%d]: Failed to unwrap error, got %+v, expected %+v", i, cause, baseErr)
+		}
+	}
+}
+
+func TestExtractExtraFollowsUnwrap(t *testing.T) {
+	err := &wrappedErr{
+		msg: "outer",
+		err: joinedErr{
+			WrapWithExtra(errors.New("first"), map[string]interface{}{"first": 1, "shared": "first"}),
+			&wrappedErr{msg: "second", err: WrapWithExtra(errors.New("inner"), map[string]interface{}{"shared": "second"})},
+			&convertibleErr{},
+		},
+	}
+
+	expected := Extra{"first": 1, "shared": "second", "foo": "bar"}
+	if extra := extractExtra(err); !reflect.DeepEqual(extra, expected) {
+		t.Errorf("Wrong extra data, got %+v, expected %+v", extra, expected)
+	}
+}
+
+func TestExtractTagsInnermostWins(t *testing.T) {
+	err := WrapWithTags(
+		WrapWithExtra(
+			WrapWithTags(errors.New("this is bad"), map[string]string{"shared": "inner", "inner": "1"}),
+			nil,
+		),
+		map[string]string{"shared": "outer", "outer": "2"},
+	)
+
+	expected := map[string]string{"shared": "inner", "inner": "1", "outer": "2"}
+	if tags := extractTags(err); !reflect.DeepEqual(tags, expected) {
+		t.Errorf("Wrong tags, got %+v, expected %+v", tags, expected)
+	}
+}
+
+func TestErrorDecorationsFollowAs(t *testing.T) {
+	err := &wrappedErr{msg: "outer", err: &convertibleErr{}}
+
+	if extra, expected := extractExtra(err), (Extra{"foo": "bar"}); !reflect.DeepEqual(extra, expected) {
+		t.Errorf("Wrong extra data, got %+v, expected %+v", extra, expected)
+	}
+	packet := NewPacket("this is bad")
+	applyErrorDecorations(packet, err)
+	if packet.Level != WARNING {
+		t.Errorf("Wrong level, got %q, expected %q", packet.Level, WARNING)
+	}
+}
+
+func TestApplyErrorDecorationsOutermostWins(t *testing.T) {
+	err := WrapWithLevel(
+		WrapWithFingerprint(
+			WrapWithUser(
+				WrapWithLevel(
+					WrapWithFingerprint(
+						WrapWithUser(errors.New("this is bad"), &User{ID: "inner"}),
+						"inner",
+					),
+					INFO,
+				),
+				&User{ID: "outer"},
+			),
+			"outer", "{{ default }}",
+		),
+		WARNING,
+	)
+
+	packet := NewPacket("this is bad")
+	applyErrorDecorations(packet, err)
+
+	if packet.Level != WARNING {
+		t.Errorf("Wrong level, got %q, expected %q", packet.Level, WARNING)
+	}
+	if expected := []string{"outer", "{{ default }}"}; !reflect.DeepEqual(packet.Fingerprint, expected) {
+		t.Errorf("Wrong fingerprint, got %v, expected %v", packet.Fingerprint, expected)
+	}
+	if user, ok := packetInterface(packet, "user").(*User); !ok || user.ID != "outer" {
+		t.Errorf("Wrong user, got %+v", packetInterface(packet, "user"))
+	}
+}
+
+func TestCaptureErrorUsesErrorDecorations(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+
+	err := WrapWithLevel(WrapWithTags(errors.New("this is bad"), map[string]string{"component": "db"}), FATAL)
+	client.CaptureError(err, map[string]string{"request": "42"})
+	client.Wait()
+
+	packet := transport.packets[0]
+	if packet.Level != FATAL {
+		t.Errorf("Wrong level, got %q, expected %q", packet.Level, FATAL)
+	}
+	tags := packetTags(packet)
+	if tags["component"] != "db" || tags["request"] != "42" {
+		t.Errorf("Wrong tags, got %+v", tags)
+	}
+	if exception, ok := packetInterface(packet, "exception").(*Exception); !ok || exception.Value != "this is bad" {
+		t.Errorf("Expected wrappers to be merged into a single exception, got %#v", packetInterface(packet, "exception"))
+	}
+}
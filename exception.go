@@ -1,15 +1,26 @@
 package raven
 
 import (
+	"fmt"
 	"reflect"
 	"regexp"
+	"strings"
 )
 
 var errorMsgPattern = regexp.MustCompile(`\A(\w+): (.+)\z`)
 
+// MaxErrorDepth is the maximum number of wrapped errors reported as chained exceptions
+var MaxErrorDepth = 10
+
+// maxUnwrapDepth bounds how deep error chains are followed, guarding against cycles
+const maxUnwrapDepth = 100
+
 // NewException constructs an Exception using provided Error and Stacktrace
 func NewException(err error, stacktrace *Stacktrace) *Exception {
-	msg := err.Error()
+	return newException(err, err.Error(), stacktrace)
+}
+
+func newException(err error, msg string, stacktrace *Stacktrace) *Exception {
 	ex := &Exception{
 		Stacktrace: stacktrace,
 		Value:      msg,
@@ -30,6 +41,17 @@ type Exception struct {
 	Type       string      `json:"type,omitempty"`
 	Module     string      `json:"module,omitempty"`
 	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+	Mechanism  *Mechanism  `json:"mechanism,omitempty"`
+}
+
+// Mechanism defines Sentry's spec compliant mechanism of an Exception, which places it in
+// the tree of an exception group - https://develop.sentry.dev/sdk/event-payloads/exception/#exception-mechanism
+type Mechanism struct {
+	Type             string `json:"type"`
+	Source           string `json:"source,omitempty"`
+	ExceptionID      int    `json:"exception_id"`
+	ParentID         *int   `json:"parent_id,omitempty"`
+	IsExceptionGroup bool   `json:"is_exception_group,omitempty"`
 }
 
 // Class provides name of implemented Sentry's interface
@@ -51,3 +73,134 @@ type Exceptions struct {
 
 // Class provides name of implemented Sentry's interface
 func (es Exceptions) Class() string { return "exception" }
+
+// Culprit tries to read top-most error message from the stacktrace of the innermost Exception having one
+func (es Exceptions) Culprit() string {
+	for _, e := range es.Values {
+		if culprit := e.Culprit(); culprit != "" {
+			return culprit
+		}
+	}
+	return ""
+}
+
+// NewExceptionChain constructs Exceptions holding one Exception per layer of err,
+// following Cause(), Unwrap() and the branches of multi-errors, innermost cause first.
+// Every layer gets the stacktrace it carries, if any, and the innermost one falls back
+// to stacktrace. Layers which only wrap their cause without changing the message are
+// merged into it, and the message of the cause is trimmed from the value of the layer
+// wrapping it. An error which doesn't wrap anything results in a single *Exception.
+//
+// When err contains multi-errors, they are reported as exception groups: every
+// Exception gets a Mechanism pointing to the one of the error wrapping it, so that
+// the branches are shown as siblings rather than as causes of each other.
+func NewExceptionChain(err error, stacktrace *Stacktrace, context int, appPackagePrefixes []string) Interface {
+	chain := &exceptionChain{
+		parents:            make(map[*Exception]*Exception),
+		sources:            make(map[*Exception]string),
+		context:            context,
+		appPackagePrefixes: appPackagePrefixes,
+	}
+	chain.add(err, 0)
+	values := chain.values
+	if len(values) == 0 {
+		return nil
+	}
+	if values[0].Stacktrace == nil {
+		values[0].Stacktrace = stacktrace
+	}
+
+	if len(values) == 1 {
+		return values[0]
+	}
+	if len(chain.groups) > 0 {
+		chain.setMechanisms()
+	}
+	return &Exceptions{Values: values}
+}
+
+// exceptionChain collects the exceptions of the layers of an error, innermost first,
+// along with the exception wrapping each of them
+type exceptionChain struct {
+	values  []*Exception
+	parents map[*Exception]*Exception
+	sources map[*Exception]string // position of the branches in their multi-error
+	groups  []*Exception
+
+	context            int
+	appPackagePrefixes []string
+}
+
+// add appends the exceptions of the causes of err, then the one of err itself, and
+// returns the exception reporting err, which may be the one of its cause, or nil
+func (c *exceptionChain) add(err error, depth int) *Exception {
+	if err == nil || len(c.values) >= MaxErrorDepth || depth >= maxUnwrapDepth {
+		return nil
+	}
+	msg := err.Error()
+	stacktrace := errorStacktrace(err, c.context, c.appPackagePrefixes)
+
+	if multi, ok := err.(multiWrapper); ok {
+		var branches []*Exception
+		for i, branch := range multi.Unwrap() {
+			if ex := c.add(branch, depth+1); ex != nil {
+				branches = append(branches, ex)
+				c.sources[ex] = fmt.Sprintf("errors[%d]", i)
+			}
+		}
+		group := newException(err, msg, stacktrace)
+		c.values = append(c.values, group)
+		if len(branches) > 0 {
+			c.groups = append(c.groups, group)
+		}
+		for _, branch := range branches {
+			c.parents[branch] = group
+		}
+		return group
+	}
+
+	cause := unwrap(err)
+	inner := c.add(cause, depth+1)
+	if inner == nil {
+		ex := newException(err, msg, stacktrace)
+		c.values = append(c.values, ex)
+		return ex
+	}
+
+	innerMsg := cause.Error()
+	if msg == innerMsg {
+		// Nothing to report for this layer, but keep its stacktrace if the cause has none
+		if inner.Stacktrace == nil {
+			inner.Stacktrace = stacktrace
+		}
+		return inner
+	}
+	if trimmed := strings.TrimSuffix(msg, ": "+innerMsg); trimmed != "" {
+		msg = trimmed
+	}
+	ex := newException(err, msg, stacktrace)
+	c.values = append(c.values, ex)
+	c.parents[inner] = ex
+	return ex
+}
+
+// setMechanisms numbers the exceptions from the outermost one, which is last, and
+// points every exception to the one wrapping it
+func (c *exceptionChain) setMechanisms() {
+	ids := make(map[*Exception]int, len(c.values))
+	for i, ex := range c.values {
+		ids[ex] = len(c.values) - 1 - i
+	}
+	for _, ex := range c.values {
+		ex.Mechanism = &Mechanism{Type: "chained", Source: c.sources[ex], ExceptionID: ids[ex]}
+		if parent, ok := c.parents[ex]; ok {
+			parentID := ids[parent]
+			ex.Mechanism.ParentID = &parentID
+		} else {
+			ex.Mechanism.Type = "generic"
+		}
+	}
+	for _, group := range c.groups {
+		group.Mechanism.IsExceptionGroup = true
+	}
+}
@@ -37,3 +37,135 @@ func TestNewException_JSON(t *testing.T) {
 		t.Errorf("incorrect JSON: got %s, want %s", string(b), expected)
 	}
 }
+
+type wrappedErr struct {
+	msg string
+	err error
+}
+
+func (e *wrappedErr) Error() string {
+	if e.msg == "" {
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+func (e *wrappedErr) Unwrap() error { return e.err }
+
+func TestNewExceptionChain(t *testing.T) {
+	root := errors.New("connection refused")
+	err := &wrappedErr{msg: "failed to save user", err: WrapWithExtra(&wrappedErr{msg: "query failed", err: root}, nil)}
+	stacktrace := &Stacktrace{Frames: []*StacktraceFrame{{Module: "raven", Function: "trace", InApp: true}}}
+
+	exceptions, ok := NewExceptionChain(err, stacktrace, 3, nil).(*Exceptions)
+	if !ok {
+		t.Fatal("expected Exceptions for a wrapped error")
+	}
+
+	expected := []string{"connection refused", "query failed", "failed to save user"}
+	if len(exceptions.Values) != len(expected) {
+		t.Fatalf("incorrect number of exceptions: got %d, want %d", len(exceptions.Values), len(expected))
+	}
+	for i, value := range expected {
+		if exceptions.Values[i].Value != value {
+			t.Errorf("incorrect Value of exception %d: got %q, want %q", i, exceptions.Values[i].Value, value)
+		}
+	}
+	if exceptions.Values[0].Type != "*errors.errorString" || exceptions.Values[2].Type != "*raven.wrappedErr" {
+		t.Errorf("incorrect Types: got %s and %s", exceptions.Values[0].Type, exceptions.Values[2].Type)
+	}
+	if exceptions.Values[0].Stacktrace != stacktrace {
+		t.Error("expected the innermost exception to get the stacktrace")
+	}
+	if exceptions.Values[1].Stacktrace != nil {
+		t.Error("expected no stacktrace for wrapping layers")
+	}
+	if exceptions.Culprit() != "raven.trace" {
+		t.Errorf("incorrect Culprit: got %q", exceptions.Culprit())
+	}
+}
+
+func TestNewExceptionChainSingleError(t *testing.T) {
+	if _, ok := NewExceptionChain(errors.New("foobar"), nil, 3, nil).(*Exception); !ok {
+		t.Error("expected a single Exception for an unwrapped error")
+	}
+}
+
+func TestCaptureErrorReportsChain(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+
+	err := &wrappedErr{msg: "failed to save user", err: errors.New("connection refused")}
+	_, ch := client.Capture(client.newErrorPacket(err, nil), nil)
+	<-ch
+
+	exceptions, ok := packetInterface(transport.packets[0], "exception").(*Exceptions)
+	if !ok || len(exceptions.Values) != 2 {
+		t.Fatalf("expected 2 chained exceptions, got %#v", packetInterface(transport.packets[0], "exception"))
+	}
+	if exceptions.Values[0].Stacktrace == nil {
+		t.Error("expected a stacktrace for the innermost exception")
+	}
+	if transport.packets[0].Message != err.Error() {
+		t.Errorf("incorrect Message: got %q, want %q", transport.packets[0].Message, err.Error())
+	}
+}
+
+func TestNewExceptionChainMultiError(t *testing.T) {
+	err := &wrappedErr{
+		msg: "batch failed",
+		err: joinedErr{
+			&wrappedErr{msg: "first", err: errors.New("timeout")},
+			errors.New("second"),
+		},
+	}
+
+	exceptions, ok := NewExceptionChain(err, nil, 3, nil).(*Exceptions)
+	if !ok {
+		t.Fatal("expected Exceptions for a multi-error")
+	}
+
+	expected := []struct {
+		value            string
+		id               int
+		parentID         int // -1 for none
+		source           string
+		isExceptionGroup bool
+	}{
+		{"timeout", 4, 3, "", false},
+		{"first", 3, 1, "errors[0]", false},
+		{"second", 2, 1, "errors[1]", false},
+		{"first: timeout\nsecond", 1, 0, "", true},
+		{"batch failed", 0, -1, "", false},
+	}
+	if len(exceptions.Values) != len(expected) {
+		t.Fatalf("incorrect number of exceptions: got %d, want %d", len(exceptions.Values), len(expected))
+	}
+	for i, want := range expected {
+		ex := exceptions.Values[i]
+		if ex.Value != want.value {
+			t.Errorf("incorrect Value of exception %d: got %q, want %q", i, ex.Value, want.value)
+		}
+		m := ex.Mechanism
+		if m == nil {
+			t.Fatalf("expected a Mechanism for exception %d", i)
+		}
+		parentID := -1
+		if m.ParentID != nil {
+			parentID = *m.ParentID
+		}
+		if m.ExceptionID != want.id || parentID != want.parentID || m.Source != want.source || m.IsExceptionGroup != want.isExceptionGroup {
+			t.Errorf("incorrect Mechanism of exception %d: got %+v with parent %d", i, m, parentID)
+		}
+	}
+}
+
+func TestNewExceptionChainWithoutGroupHasNoMechanism(t *testing.T) {
+	err := &wrappedErr{msg: "failed to save user", err: errors.New("connection refused")}
+	exceptions := NewExceptionChain(err, nil, 3, nil).(*Exceptions)
+	for _, ex := range exceptions.Values {
+		if ex.Mechanism != nil {
+			t.Errorf("expected no Mechanism for a linear chain, got %+v", ex.Mechanism)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/getsentry/raven-go
+
+go 1.13
+
+require (
+	github.com/certifi/gocertifi v0.0.0-20210507211836-431795d63e8d
+	github.com/pkg/errors v0.9.1
+)
@@ -1,30 +1,47 @@
 package raven
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
 	"runtime/debug"
 	"strings"
+	"time"
 )
 
-// NewHttp creates new HTTP object that follows Sentry's HTTP interface spec and will be attached to the Packet
+// NewHttp creates new HTTP object that follows Sentry's HTTP interface spec and will be attached to the Packet.
+// When the request comes from a trusted proxy, the client address, scheme and host are the ones it forwarded,
+// see SetTrustedProxies. Secrets are left as is, to be masked by the Scrubber of the client capturing it.
 func NewHttp(req *http.Request) *Http {
 	proto := "http"
-	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+	if req.TLS != nil {
 		proto = "https"
 	}
+	host := req.Host
+	if remoteIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil && isTrustedProxy(net.ParseIP(remoteIP)) {
+		if forwarded := forwardedProto(req); forwarded == "http" || forwarded == "https" {
+			proto = forwarded
+		}
+		if forwarded := forwardedHost(req); forwarded != "" {
+			host = forwarded
+		}
+	}
 	h := &Http{
 		Method:  req.Method,
 		Cookies: req.Header.Get("Cookie"),
-		Query:   sanitizeQuery(req.URL.Query()).Encode(),
-		URL:     proto + "://" + req.Host + req.URL.Path,
+		Query:   req.URL.RawQuery,
+		URL:     proto + "://" + host + req.URL.Path,
 		Headers: make(map[string]string, len(req.Header)),
 	}
-	if addr, port, err := net.SplitHostPort(req.RemoteAddr); err == nil {
-		h.Env = map[string]string{"REMOTE_ADDR": addr, "REMOTE_PORT": port}
+	if addr, port := clientAddr(req); addr != "" {
+		h.Env = map[string]string{"REMOTE_ADDR": addr}
+		if port != "" {
+			h.Env["REMOTE_PORT"] = port
+		}
 	}
 	for k, v := range req.Header {
 		h.Headers[k] = strings.Join(v, ",")
@@ -33,19 +50,6 @@ func NewHttp(req *http.Request) *Http {
 	return h
 }
 
-var querySecretFields = []string{"password", "passphrase", "passwd", "secret"}
-
-func sanitizeQuery(query url.Values) url.Values {
-	for _, keyword := range querySecretFields {
-		for field := range query {
-			if strings.Contains(field, keyword) {
-				query[field] = []string{"********"}
-			}
-		}
-	}
-	return query
-}
-
 // Http defines Sentry's spec compliant interface holding Request information - https://docs.sentry.io/development/sdk-dev/interfaces/http/
 type Http struct {
 	// Required
@@ -74,28 +78,147 @@ func RecoveryHandler(handler func(http.ResponseWriter, *http.Request)) func(http
 	return Recoverer(http.HandlerFunc(handler)).ServeHTTP
 }
 
+// RecovererOptions configures the middleware returned by RecovererWithOptions
+type RecovererOptions struct {
+	// Client captures the panics. Defaults to the default *Client.
+	Client *Client
+
+	// Repanic makes the middleware panic again once the panic is captured, so that
+	// it can be handled by other middleware. No response is written then.
+	Repanic bool
+
+	// WaitForDelivery is how long to wait for the panic to be sent before
+	// responding or panicking again. Zero does not wait.
+	WaitForDelivery time.Duration
+
+	// ErrorResponse writes the response to the request which panicked, given the ID
+	// of the captured event, empty if it was not sent. Defaults to a bare 500 response.
+	ErrorResponse func(w http.ResponseWriter, r *http.Request, eventID string, rval interface{})
+
+	// MaxRequestBodySize is the number of bytes of the request body read by the
+	// handler which are reported as the data of the request. Zero does not report the body.
+	MaxRequestBodySize int64
+
+	// PrintStack prints the stack of the panicking goroutine to standard error
+	PrintStack bool
+}
+
 // Recoverer wraps the stdlib net/http Mux.
 // Example:
 //  mux := http.NewServeMux
 //  ...
 //	http.Handle("/", raven.Recoverer(mux))
 func Recoverer(handler http.Handler) http.Handler {
+	return RecovererWithOptions(handler, &RecovererOptions{PrintStack: true})
+}
+
+// RecovererWithOptions is identical to Recoverer, except that it is configured
+// by opts instead of printing the stack and writing a bare 500 response.
+// Example:
+//	http.Handle("/", raven.RecovererWithOptions(mux, &raven.RecovererOptions{
+//		Client:          client,
+//		WaitForDelivery: 2 * time.Second,
+//		ErrorResponse:   renderErrorPage,
+//	}))
+func RecovererWithOptions(handler http.Handler, opts *RecovererOptions) http.Handler {
+	if opts == nil {
+		opts = &RecovererOptions{}
+	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body *bodyRecorder
+		if opts.MaxRequestBodySize > 0 && r.Body != nil {
+			body = &bodyRecorder{ReadCloser: r.Body, max: opts.MaxRequestBodySize}
+			r.Body = body
+		}
+
 		defer func() {
 			if rval := recover(); rval != nil {
-				debug.PrintStack()
-				rvalStr := fmt.Sprint(rval)
-				var packet *Packet
-				if err, ok := rval.(error); ok {
-					packet = NewPacket(rvalStr, NewException(errors.New(rvalStr), GetOrNewStacktrace(err, 2, 3, nil)), NewHttp(r))
-				} else {
-					packet = NewPacket(rvalStr, NewException(errors.New(rvalStr), NewStacktrace(2, 3, nil)), NewHttp(r))
-				}
-				Capture(packet, nil)
-				w.WriteHeader(http.StatusInternalServerError)
+				opts.recover(w, r, body, rval)
 			}
 		}()
 
 		handler.ServeHTTP(w, r)
 	})
 }
+
+// recover captures the panic, then panics again or writes the error response
+func (opts *RecovererOptions) recover(w http.ResponseWriter, r *http.Request, body *bodyRecorder, rval interface{}) {
+	if opts.PrintStack {
+		debug.PrintStack()
+	}
+	client := opts.Client
+	if client == nil {
+		client = DefaultClient
+	}
+
+	h := NewHttp(r)
+	if body != nil && body.buf.Len() > 0 {
+		h.Data = requestData(r, body)
+	}
+
+	// Skip recover, the deferred function and runtime.gopanic
+	rvalStr := fmt.Sprint(rval)
+	var stacktrace *Stacktrace
+	if err, ok := rval.(error); ok {
+		stacktrace = GetOrNewStacktrace(err, 3, 3, client.IncludePaths())
+	} else {
+		stacktrace = NewStacktrace(3, 3, client.IncludePaths())
+	}
+	packet := NewPacket(rvalStr, NewException(errors.New(rvalStr), stacktrace), h)
+	packet.Interfaces = append(packet.Interfaces, client.panicInterfaces()...)
+	eventID, ch := client.CaptureCtx(r.Context(), packet, nil)
+
+	if eventID != "" && opts.WaitForDelivery > 0 {
+		select {
+		case <-ch:
+		case <-time.After(opts.WaitForDelivery):
+		}
+	}
+
+	if opts.Repanic {
+		panic(rval)
+	}
+	if opts.ErrorResponse != nil {
+		opts.ErrorResponse(w, r, eventID, rval)
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// bodyRecorder copies up to max bytes of the request body as the handler reads it,
+// so that the body is neither read ahead of the handler nor kept whole in memory
+type bodyRecorder struct {
+	io.ReadCloser
+	max       int64
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *bodyRecorder) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if room := b.max - int64(b.buf.Len()); int64(n) > room {
+		b.buf.Write(p[:room])
+		b.truncated = true
+	} else {
+		b.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+// requestData returns the body as Sentry's request data. Secrets are masked by
+// the Scrubber of the client capturing it.
+func requestData(r *http.Request, body *bodyRecorder) interface{} {
+	if body.truncated {
+		return body.buf.String() + "..."
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(body.buf.String()); err == nil {
+			data := make(map[string]string, len(form))
+			for k, v := range form {
+				data[k] = strings.Join(v, ",")
+			}
+			return data
+		}
+	}
+	return body.buf.String()
+}
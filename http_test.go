@@ -1,10 +1,15 @@
 package raven
 
 import (
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 type Testcase struct {
@@ -86,12 +91,22 @@ func NewCookiesRequest() Testcase {
 	return Testcase{req, h}
 }
 
+func NewQueryRequest() Testcase {
+	req := newBaseRequest()
+	req.URL.RawQuery = "id=1&password=hunter2"
+
+	h := newBaseHttp()
+	h.Query = "id=1&password=hunter2"
+	return Testcase{req, h}
+}
+
 var newHttpTests = []Testcase{
 	NewRequest(),
 	NewRequestIPV6(),
 	NewRequestMultipleHeaders(),
 	NewSecureRequest(),
 	NewCookiesRequest(),
+	NewQueryRequest(),
 }
 
 func TestNewHttp(t *testing.T) {
@@ -121,30 +136,152 @@ func TestNewHttp(t *testing.T) {
 	}
 }
 
-var sanitizeQueryTests = []struct {
-	input, output string
-}{
-	{"foo=bar", "foo=bar"},
-	{"password=foo", "password=********"},
-	{"passphrase=foo", "passphrase=********"},
-	{"passwd=foo", "passwd=********"},
-	{"secret=foo", "secret=********"},
-	{"secretstuff=foo", "secretstuff=********"},
-	{"foo=bar&secret=foo", "foo=bar&secret=********"},
-	{"secret=foo&secret=bar", "secret=********"},
+func TestRecovererWithOptions(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+
+	var handlerBody string
+	handler := RecovererWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		handlerBody = string(body)
+		panic("nil user")
+	}), &RecovererOptions{
+		Client:             client,
+		WaitForDelivery:    time.Second,
+		MaxRequestBodySize: 64,
+		ErrorResponse: func(w http.ResponseWriter, r *http.Request, eventID string, rval interface{}) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(eventID))
+		},
+	})
+
+	req := httptest.NewRequest("POST", "http://example.com/users", strings.NewReader("name=bob&password=hunter2"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+
+	if handlerBody != "name=bob&password=hunter2" {
+		t.Errorf("expected the handler to read the whole body, got %q", handlerBody)
+	}
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the panic to be captured, got %d packets", len(transport.packets))
+	}
+	packet := transport.packets[0]
+	if res.Code != http.StatusServiceUnavailable || res.Body.String() != packet.EventID {
+		t.Errorf("incorrect response: got %d %q", res.Code, res.Body.String())
+	}
+	h, _ := packetInterface(packet, "request").(*Http)
+	if h == nil {
+		t.Fatal("expected the request to be attached to the packet")
+	}
+	if want := map[string]string{"name": "bob", "password": "********"}; !reflect.DeepEqual(h.Data, want) {
+		t.Errorf("incorrect request data: got %#v, want %#v", h.Data, want)
+	}
+}
+
+func TestRecovererWithOptionsTruncatesBody(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := RecovererWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		panic("nil user")
+	}), &RecovererOptions{Client: client, MaxRequestBodySize: 4})
+
+	req := httptest.NewRequest("POST", "http://example.com/users", strings.NewReader(`{"name":"bob"}`))
+	res := httptest.NewRecorder()
+	handler.ServeHTTP(res, req)
+	client.Wait()
+
+	if res.Code != http.StatusInternalServerError {
+		t.Errorf("expected a bare 500 response, got %d", res.Code)
+	}
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the panic to be captured, got %d packets", len(transport.packets))
+	}
+	if h, _ := packetInterface(transport.packets[0], "request").(*Http); h == nil || h.Data != `{"na...` {
+		t.Errorf("expected the body to be truncated, got %+v", h)
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	read int
 }
 
-func parseQuery(q string) url.Values {
-	r, _ := url.ParseQuery(q)
-	return r
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
 }
 
-func TestSanitizeQuery(t *testing.T) {
-	for _, test := range sanitizeQueryTests {
-		actual := sanitizeQuery(parseQuery(test.input))
-		expected := parseQuery(test.output)
-		if !reflect.DeepEqual(actual, expected) {
-			t.Errorf("incorrect sanitization: got %+v, want %+v", actual, expected)
+func TestRecovererWithOptionsOnlyRecordsReadBody(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	body := &countingReader{Reader: strings.NewReader("name=bob&password=hunter2")}
+	handler := RecovererWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body.read != 0 {
+			t.Errorf("expected the body not to be read ahead of the handler, got %d bytes read", body.read)
 		}
+		r.Body.Read(make([]byte, 8))
+		panic("nil user")
+	}), &RecovererOptions{Client: client, MaxRequestBodySize: 64})
+
+	req := httptest.NewRequest("POST", "http://example.com/users", body)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the panic to be captured, got %d packets", len(transport.packets))
+	}
+	if h, _ := packetInterface(transport.packets[0], "request").(*Http); h == nil || h.Data != "name=bob" {
+		t.Errorf("expected the body read by the handler to be reported, got %+v", h)
+	}
+}
+
+func TestRecovererWithOptionsScrubsTruncatedBody(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := RecovererWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		panic("nil user")
+	}), &RecovererOptions{Client: client, MaxRequestBodySize: 30})
+
+	req := httptest.NewRequest("POST", "http://example.com/users", strings.NewReader(`{"password":"hunter2","blob":"0123456789"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the panic to be captured, got %d packets", len(transport.packets))
+	}
+	h, _ := packetInterface(transport.packets[0], "request").(*Http)
+	if h == nil || h.Data != `{"password":"********","blob":"...` {
+		t.Errorf("expected the truncated body to be scrubbed, got %+v", h)
+	}
+}
+
+func TestRecovererWithOptionsRepanics(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := RecovererWithOptions(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil user")
+	}), &RecovererOptions{Client: client, Repanic: true})
+
+	res := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if rval := recover(); rval != "nil user" {
+				t.Errorf("expected the panic to be propagated, got %v", rval)
+			}
+		}()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", "http://example.com/", nil))
+	}()
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Errorf("expected the panic to be captured, got %d packets", len(transport.packets))
+	}
+	if res.Code != http.StatusOK || res.Body.Len() != 0 {
+		t.Errorf("expected no response to be written, got %d", res.Code)
 	}
 }
@@ -0,0 +1,52 @@
+package raven
+
+// EventProcessor is called with every packet captured by a Client, after it was
+// initialized and before it is queued for delivery. It can mutate the packet,
+// return a different one, or return nil to drop it.
+type EventProcessor func(packet *Packet) *Packet
+
+// AddEventProcessor appends a processor to the chain run on every captured packet.
+// Processors run in the order they were added, followed by the BeforeSend hook.
+func (client *Client) AddEventProcessor(processor EventProcessor) {
+	if processor == nil {
+		return
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.eventProcessors = append(client.eventProcessors, processor)
+}
+
+// SetBeforeSend sets a hook called last, after all event processors, with every
+// packet about to be queued. Returning nil drops the packet. Passing nil removes the hook.
+func (client *Client) SetBeforeSend(beforeSend func(packet *Packet) *Packet) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.beforeSend = beforeSend
+}
+
+// AddEventProcessor appends a processor to the chain of the default *Client
+func AddEventProcessor(processor EventProcessor) { DefaultClient.AddEventProcessor(processor) }
+
+// SetBeforeSend sets the BeforeSend hook of the default *Client
+func SetBeforeSend(beforeSend func(packet *Packet) *Packet) { DefaultClient.SetBeforeSend(beforeSend) }
+
+// processPacket runs the packet through the event processors and the BeforeSend
+// hook, and returns nil as soon as one of them drops it
+func (client *Client) processPacket(packet *Packet) *Packet {
+	client.mu.RLock()
+	processors := client.eventProcessors
+	beforeSend := client.beforeSend
+	client.mu.RUnlock()
+
+	if beforeSend != nil {
+		processors = append(processors[:len(processors):len(processors)], beforeSend)
+	}
+
+	for _, processor := range processors {
+		if packet = processor(packet); packet == nil {
+			debugLogger.Println("packet dropped by an event processor")
+			return nil
+		}
+	}
+	return packet
+}
@@ -0,0 +1,72 @@
+package raven
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEventProcessorsRunInOrder(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+
+	var calls []string
+	client.SetBeforeSend(func(packet *Packet) *Packet {
+		calls = append(calls, "beforeSend")
+		packet.Extra["build"] = "1234"
+		return packet
+	})
+	client.AddEventProcessor(func(packet *Packet) *Packet {
+		calls = append(calls, "first")
+		packet.ServerName = "redacted"
+		return packet
+	})
+	client.AddEventProcessor(func(packet *Packet) *Packet {
+		calls = append(calls, "second")
+		return packet
+	})
+
+	_, ch := client.Capture(NewPacket("processed"), nil)
+	if err := <-ch; err != nil {
+		t.Fatal("delivery should not fail:", err)
+	}
+
+	expected := []string{"first", "second", "beforeSend"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("incorrect processor calls: got %v, want %v", calls, expected)
+	}
+
+	packet := transport.packets[0]
+	if packet.ServerName != "redacted" {
+		t.Errorf("expected processor changes to be kept, got ServerName %q", packet.ServerName)
+	}
+	if packet.Extra["build"] != "1234" {
+		t.Errorf("expected BeforeSend changes to be kept, got Extra %v", packet.Extra)
+	}
+}
+
+func TestEventProcessorDropsPacket(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+
+	var beforeSendCalled bool
+	client.AddEventProcessor(func(packet *Packet) *Packet { return nil })
+	client.SetBeforeSend(func(packet *Packet) *Packet {
+		beforeSendCalled = true
+		return packet
+	})
+
+	eventID, ch := client.Capture(NewPacket("dropped"), nil)
+	if eventID != "" {
+		t.Error("expected empty eventID:", eventID)
+	}
+	if err := <-ch; err != ErrPacketFiltered {
+		t.Errorf("expected ErrPacketFiltered, got %v", err)
+	}
+	if beforeSendCalled {
+		t.Error("expected BeforeSend not to be called for a dropped packet")
+	}
+	client.Wait()
+	if len(transport.packets) != 0 {
+		t.Errorf("expected no packet to be sent, got %d", len(transport.packets))
+	}
+}
@@ -0,0 +1,198 @@
+package raven
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultTrustedProxies are the proxies trusted unless SetTrustedProxies is called:
+// loopback addresses only, such as a reverse proxy running on the same host. Load
+// balancers on private networks must be trusted explicitly, otherwise any client on
+// those networks could spoof its address and the requested host.
+var DefaultTrustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+var trustedProxies = struct {
+	sync.RWMutex
+	nets []*net.IPNet
+}{nets: mustParseNetworks(DefaultTrustedProxies)}
+
+// SetTrustedProxies sets the addresses of the proxies, as IPs or CIDR ranges, whose
+// Forwarded, X-Forwarded-For, X-Real-Ip, X-Forwarded-Proto and X-Forwarded-Host headers
+// are trusted by NewHttp and ClientIP to find the address of the client and the
+// scheme and host it requested, instead of DefaultTrustedProxies. Passing no address
+// trusts no proxy.
+//
+//	raven.SetTrustedProxies([]string{"10.0.0.0/8"})
+func SetTrustedProxies(proxies []string) error {
+	nets, err := parseNetworks(proxies)
+	if err != nil {
+		return err
+	}
+	trustedProxies.Lock()
+	defer trustedProxies.Unlock()
+	trustedProxies.nets = nets
+	return nil
+}
+
+func parseNetworks(addrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(addrs))
+	for _, addr := range addrs {
+		if !strings.Contains(addr, "/") {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("raven: invalid trusted proxy %q", addr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("raven: invalid trusted proxy %q: %v", addr, err)
+		}
+		nets = append(nets, network)
+	}
+	return nets, nil
+}
+
+func mustParseNetworks(addrs []string) []*net.IPNet {
+	nets, err := parseNetworks(addrs)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy
+func isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	trustedProxies.RLock()
+	defer trustedProxies.RUnlock()
+	for _, network := range trustedProxies.nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client which sent the request. When the request
+// comes from a trusted proxy, it is the last address of the Forwarded, X-Forwarded-For
+// or X-Real-Ip headers which isn't a trusted proxy.
+func ClientIP(r *http.Request) string {
+	ip, _ := clientAddr(r)
+	return ip
+}
+
+// clientAddr returns the address of the client, along with its port when it is
+// directly connected
+func clientAddr(r *http.Request) (ip, port string) {
+	ip, port, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip, port = r.RemoteAddr, ""
+	}
+	if !isTrustedProxy(net.ParseIP(ip)) {
+		return ip, port
+	}
+	if forwarded := forwardedClientIP(r); forwarded != "" {
+		return forwarded, ""
+	}
+	return ip, port
+}
+
+// forwardedClientIP walks the addresses forwarded by the proxies from the nearest one,
+// and returns the first which isn't a trusted proxy, or the farthest one if they all are
+func forwardedClientIP(r *http.Request) string {
+	var addrs []string
+	if elements := forwardedElements(r); len(elements) > 0 {
+		for _, element := range elements {
+			addrs = append(addrs, element["for"])
+		}
+	} else if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		addrs = splitHeader(strings.Join(r.Header["X-Forwarded-For"], ","))
+	} else if realIP := r.Header.Get("X-Real-Ip"); realIP != "" {
+		addrs = []string{realIP}
+	}
+
+	var client string
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip := net.ParseIP(stripPort(addrs[i]))
+		if ip == nil {
+			// Obfuscated or unknown hops can't be followed
+			break
+		}
+		client = ip.String()
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedProto returns the scheme requested by the client, as forwarded by a trusted proxy
+func forwardedProto(r *http.Request) string {
+	if elements := forwardedElements(r); len(elements) > 0 {
+		return strings.ToLower(elements[0]["proto"])
+	}
+	if proto := splitHeader(r.Header.Get("X-Forwarded-Proto")); len(proto) > 0 {
+		return strings.ToLower(proto[0])
+	}
+	return ""
+}
+
+// forwardedHost returns the host requested by the client, as forwarded by a trusted proxy
+func forwardedHost(r *http.Request) string {
+	if elements := forwardedElements(r); len(elements) > 0 {
+		return elements[0]["host"]
+	}
+	if host := splitHeader(r.Header.Get("X-Forwarded-Host")); len(host) > 0 {
+		return host[0]
+	}
+	return ""
+}
+
+// forwardedElements parses the RFC 7239 Forwarded headers into one map of
+// lower-cased parameters per proxy, from the farthest to the nearest
+func forwardedElements(r *http.Request) []map[string]string {
+	var elements []map[string]string
+	for _, element := range splitHeader(strings.Join(r.Header["Forwarded"], ",")) {
+		params := make(map[string]string)
+		for _, pair := range strings.Split(element, ";") {
+			eq := strings.IndexByte(pair, '=')
+			if eq < 0 {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(pair[:eq]))
+			params[key] = strings.Trim(strings.TrimSpace(pair[eq+1:]), `"`)
+		}
+		elements = append(elements, params)
+	}
+	return elements
+}
+
+// splitHeader splits a comma-separated header, trimming the values
+func splitHeader(header string) []string {
+	var values []string
+	for _, value := range strings.Split(header, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// stripPort removes the port, and the brackets of IPv6 addresses, from a forwarded address
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
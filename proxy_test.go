@@ -0,0 +1,88 @@
+package raven
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	defer SetTrustedProxies(DefaultTrustedProxies)
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "203.0.113.7"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"198.51.100.1:5000", nil, "198.51.100.1"},
+		{"198.51.100.1:5000", map[string]string{"X-Forwarded-For": "192.0.2.1"}, "198.51.100.1"},
+		{"10.0.0.1:5000", map[string]string{"X-Forwarded-For": "192.0.2.1, 198.51.100.2, 203.0.113.7"}, "198.51.100.2"},
+		{"10.0.0.1:5000", map[string]string{"X-Forwarded-For": "10.0.0.2, 10.0.0.3"}, "10.0.0.2"},
+		{"10.0.0.1:5000", map[string]string{"X-Real-Ip": "192.0.2.1"}, "192.0.2.1"},
+		{"10.0.0.1:5000", map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`, "X-Forwarded-For": "192.0.2.1"}, "2001:db8::1"},
+		{"10.0.0.1:5000", map[string]string{"Forwarded": "for=_hidden"}, "10.0.0.1"},
+		{"10.0.0.1:5000", nil, "10.0.0.1"},
+	} {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = test.remoteAddr
+		for key, value := range test.headers {
+			req.Header.Set(key, value)
+		}
+		if ip := ClientIP(req); ip != test.want {
+			t.Errorf("incorrect client IP from %s with %v: got %q, want %q", test.remoteAddr, test.headers, ip, test.want)
+		}
+	}
+}
+
+func TestNewHttpBehindProxy(t *testing.T) {
+	defer SetTrustedProxies(DefaultTrustedProxies)
+	if err := SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "http://lb.internal/users", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "192.0.2.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "example.com")
+	h := NewHttp(req)
+	if h.URL != "https://example.com/users" || h.Env["REMOTE_ADDR"] != "192.0.2.1" || h.Env["REMOTE_PORT"] != "" {
+		t.Errorf("expected the forwarded request to be reported, got %s from %v", h.URL, h.Env)
+	}
+
+	req.RemoteAddr = "198.51.100.1:5000"
+	h = NewHttp(req)
+	if h.URL != "http://lb.internal/users" || h.Env["REMOTE_ADDR"] != "198.51.100.1" || h.Env["REMOTE_PORT"] != "5000" {
+		t.Errorf("expected the headers of an untrusted client to be ignored, got %s from %v", h.URL, h.Env)
+	}
+}
+
+func TestSetTrustedProxiesInvalid(t *testing.T) {
+	defer SetTrustedProxies(DefaultTrustedProxies)
+	if err := SetTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected an invalid range to be rejected")
+	}
+	if err := SetTrustedProxies([]string{"lb.internal"}); err == nil {
+		t.Error("expected a host name to be rejected")
+	}
+}
+
+func TestDefaultTrustedProxiesOnlyLoopback(t *testing.T) {
+	for _, test := range []struct {
+		remoteAddr, want string
+	}{
+		{"127.0.0.1:5000", "192.0.2.1"},
+		{"[::1]:5000", "192.0.2.1"},
+		{"10.0.0.1:5000", "10.0.0.1"},
+		{"192.168.1.1:5000", "192.168.1.1"},
+	} {
+		req := httptest.NewRequest("GET", "http://example.com/", nil)
+		req.RemoteAddr = test.remoteAddr
+		req.Header.Set("X-Forwarded-For", "192.0.2.1")
+		if ip := ClientIP(req); ip != test.want {
+			t.Errorf("incorrect client IP from %s: got %q, want %q", test.remoteAddr, ip, test.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package raven
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit category used for all packets sent by this SDK
+const categoryError = "error"
+
+// Backoff applied when the server answers with 429 but doesn't say for how long
+const defaultRateLimitBackoff = 60 * time.Second
+
+// RateLimitedTransport is implemented by transports which keep track of server
+// side rate limits. Client.Capture uses it to drop packets early, without queueing
+// them, while a rate limit is in place.
+type RateLimitedTransport interface {
+	Transport
+	RateLimited(category string) bool
+}
+
+// rateLimits keeps track of when every rate limited category may be sent again.
+// An empty category applies to all categories.
+type rateLimits struct {
+	mu        sync.RWMutex
+	deadlines map[string]time.Time
+}
+
+func (r *rateLimits) isRateLimited(category string, now time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return now.Before(r.deadlines[category]) || now.Before(r.deadlines[""])
+}
+
+func (r *rateLimits) limit(category string, deadline time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.deadlines == nil {
+		r.deadlines = make(map[string]time.Time)
+	}
+	if deadline.After(r.deadlines[category]) {
+		r.deadlines[category] = deadline
+	}
+}
+
+// update records rate limits announced by a server response. X-Sentry-Rate-Limits
+// takes precedence over Retry-After, which is only considered for 429 responses.
+func (r *rateLimits) update(res *http.Response, now time.Time) {
+	if header := res.Header.Get("X-Sentry-Rate-Limits"); header != "" {
+		for category, backoff := range parseRateLimits(header) {
+			r.limit(category, now.Add(backoff))
+		}
+		return
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		backoff, ok := parseRetryAfter(res.Header.Get("Retry-After"), now)
+		if !ok {
+			backoff = defaultRateLimitBackoff
+		}
+		r.limit("", now.Add(backoff))
+	}
+}
+
+// parseRateLimits parses the X-Sentry-Rate-Limits header, which is a comma separated
+// list of "retry_after:categories:scope:reason_code" limits. Categories are separated
+// by semicolons, and an empty list applies the limit to all categories.
+func parseRateLimits(header string) map[string]time.Duration {
+	limits := make(map[string]time.Duration)
+
+	for _, limit := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(limit), ":")
+		if len(parts) < 2 {
+			continue
+		}
+
+		seconds, err := strconv.ParseFloat(parts[0], 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		backoff := time.Duration(seconds * float64(time.Second))
+
+		categories := strings.Split(parts[1], ";")
+		for _, category := range categories {
+			category = strings.TrimSpace(category)
+			if backoff > limits[category] {
+				limits[category] = backoff
+			}
+		}
+	}
+
+	return limits
+}
+
+// parseRetryAfter parses the Retry-After header, which is either a number of seconds
+// or an HTTP date.
+func parseRetryAfter(header string, now time.Time) (time.Duration, bool) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if backoff := date.Sub(now); backoff > 0 {
+			return backoff, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
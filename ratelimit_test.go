@@ -0,0 +1,152 @@
+package raven
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected map[string]time.Duration
+	}{
+		{"", map[string]time.Duration{}},
+		{"60::organization", map[string]time.Duration{"": 60 * time.Second}},
+		{"60:error:organization", map[string]time.Duration{"error": 60 * time.Second}},
+		{"2.5:error;transaction:key", map[string]time.Duration{"error": 2500 * time.Millisecond, "transaction": 2500 * time.Millisecond}},
+		{"10:error:key, 30:error;session:organization", map[string]time.Duration{"error": 30 * time.Second, "session": 30 * time.Second}},
+		{"invalid:error:key, 5:session:key:reason", map[string]time.Duration{"session": 5 * time.Second}},
+	}
+
+	for _, test := range testCases {
+		if actual := parseRateLimits(test.header); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("incorrect rate limits for %q: got %v, want %v", test.header, actual, test.expected)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2000, 01, 01, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{"", 0, false},
+		{"120", 120 * time.Second, true},
+		{"-1", 0, false},
+		{"Sat, 01 Jan 2000 00:00:30 GMT", 30 * time.Second, true},
+		{"Fri, 31 Dec 1999 23:59:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, test := range testCases {
+		actual, ok := parseRetryAfter(test.header, now)
+		if actual != test.expected || ok != test.ok {
+			t.Errorf("incorrect backoff for %q: got %s, %t, want %s, %t", test.header, actual, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestRateLimitsUpdate(t *testing.T) {
+	now := time.Now()
+
+	limits := &rateLimits{}
+	limits.update(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"10"}}}, now)
+	if !limits.isRateLimited(categoryError, now.Add(9*time.Second)) {
+		t.Error("expected all categories to be rate limited")
+	}
+	if limits.isRateLimited(categoryError, now.Add(11*time.Second)) {
+		t.Error("expected rate limit to expire")
+	}
+
+	limits = &rateLimits{}
+	limits.update(&http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Sentry-Rate-Limits": {"10:transaction:key"}}}, now)
+	if limits.isRateLimited(categoryError, now) {
+		t.Error("expected error category not to be rate limited")
+	}
+	if !limits.isRateLimited("transaction", now) {
+		t.Error("expected transaction category to be rate limited")
+	}
+
+	limits = &rateLimits{}
+	limits.update(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}, now)
+	if !limits.isRateLimited(categoryError, now.Add(defaultRateLimitBackoff-time.Second)) {
+		t.Error("expected default backoff without Retry-After")
+	}
+}
+
+func TestHTTPTransportHonorsRateLimits(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	transport := &HTTPTransport{Client: &http.Client{}}
+	transport.SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:          3,
+		BaseBackoff:          time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusTooManyRequests},
+	})
+
+	if err := transport.Send(server.URL, "", NewPacket("limited")); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if err := transport.Send(server.URL, "", NewPacket("limited")); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("incorrect number of requests: got %d, want 1", requests)
+	}
+	if !transport.RateLimited(categoryError) {
+		t.Error("expected transport to be rate limited")
+	}
+
+	client := newTestClient(transport)
+	eventID, ch := client.Capture(NewPacket("limited"), nil)
+	if eventID != "" {
+		t.Error("expected empty eventID:", eventID)
+	}
+	if err := <-ch; err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestHTTPTransportStopsRetryingWhenRateLimited(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("X-Sentry-Rate-Limits", "60:error:key")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	transport := &HTTPTransport{Client: &http.Client{}}
+	transport.SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:          4,
+		BaseBackoff:          time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	})
+
+	if err := transport.Send(server.URL, "", NewPacket("limited")); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	envelope := NewEnvelope("abc")
+	envelope.AddItem("session", []byte("{}"))
+	envelope.AddItem(EnvelopeItemEvent, []byte("{}"))
+	transport.limits = rateLimits{}
+	if err := transport.SendEnvelope(server.URL, "", envelope); err != ErrRateLimited {
+		t.Errorf("expected ErrRateLimited, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("incorrect number of requests: got %d, want 2", requests)
+	}
+}
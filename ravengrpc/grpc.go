@@ -0,0 +1,247 @@
+//go:build go1.19
+// +build go1.19
+
+// Package ravengrpc provides gRPC interceptors reporting panics and errors to Sentry.
+//
+// The server interceptors give every call its own raven.Scope, carried by the call's
+// context, with the full method name as culprit and the peer address and metadata as
+// request. Errors captured with raven.CaptureErrorCtx from handlers are reported with them.
+//
+//	server := grpc.NewServer(
+//		grpc.UnaryInterceptor(ravengrpc.UnaryServerInterceptor(nil)),
+//		grpc.StreamInterceptor(ravengrpc.StreamServerInterceptor(nil)),
+//	)
+//
+// It is a separate module, so that raven-go itself does not depend on gRPC, and
+// requires Go 1.19 or later.
+package ravengrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/getsentry/raven-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultCaptureCodes are the status codes of the errors captured when Options.CaptureCodes is nil
+var DefaultCaptureCodes = []codes.Code{codes.Unknown, codes.Internal, codes.DataLoss}
+
+// Metadata keys whose values are never reported, keys containing one of them are scrubbed as well
+var metadataSecretKeys = []string{"authorization", "cookie", "token", "secret", "password", "api-key"}
+
+// Options configures the interceptors
+type Options struct {
+	// Client reports the events. Defaults to raven.DefaultClient.
+	Client *raven.Client
+
+	// CaptureCodes lists the status codes of the errors captured as events.
+	// Defaults to DefaultCaptureCodes.
+	CaptureCodes []codes.Code
+
+	// Repanic makes the server interceptors panic again once a panic is captured,
+	// instead of failing the call with an Internal error
+	Repanic bool
+}
+
+func (o *Options) client() *raven.Client {
+	if o == nil || o.Client == nil {
+		return raven.DefaultClient
+	}
+	return o.Client
+}
+
+func (o *Options) captures(err error) bool {
+	if err == nil {
+		return false
+	}
+	captureCodes := DefaultCaptureCodes
+	if o != nil && o.CaptureCodes != nil {
+		captureCodes = o.CaptureCodes
+	}
+	code := status.Code(err)
+	for _, c := range captureCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor recovers the panics of unary handlers and captures their errors
+func UnaryServerInterceptor(opts *Options) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		ctx = newServerScope(ctx, info.FullMethod)
+		defer func() {
+			if rval := recover(); rval != nil {
+				err = recoverPanic(ctx, opts, info.FullMethod, rval)
+			}
+		}()
+
+		resp, err = handler(ctx, req)
+		if opts.captures(err) {
+			opts.client().CaptureErrorCtx(ctx, err, errorTags(info.FullMethod, err))
+		}
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor recovers the panics of stream handlers and captures their errors
+func StreamServerInterceptor(opts *Options) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx := newServerScope(ss.Context(), info.FullMethod)
+		defer func() {
+			if rval := recover(); rval != nil {
+				err = recoverPanic(ctx, opts, info.FullMethod, rval)
+			}
+		}()
+
+		err = handler(srv, &scopedServerStream{ServerStream: ss, ctx: ctx})
+		if opts.captures(err) {
+			opts.client().CaptureErrorCtx(ctx, err, errorTags(info.FullMethod, err))
+		}
+		return err
+	}
+}
+
+// UnaryClientInterceptor records outgoing calls as breadcrumbs and captures their errors
+func UnaryClientInterceptor(opts *Options) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, callOpts...)
+		recordCall(ctx, opts, method, cc.Target(), start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor records outgoing streams as breadcrumbs and captures the errors opening them
+func StreamClientInterceptor(opts *Options) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, callOpts...)
+		recordCall(ctx, opts, method, cc.Target(), start, err)
+		return stream, err
+	}
+}
+
+// scopedServerStream carries the scope of the call in its context
+type scopedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *scopedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// newServerScope returns a copy of ctx carrying a new scope describing the call
+func newServerScope(ctx context.Context, fullMethod string) context.Context {
+	ctx = raven.WithScope(ctx)
+	scope := raven.ScopeFromContext(ctx)
+	scope.SetCulprit(fullMethod)
+	scope.SetHttp(newRequest(ctx, fullMethod))
+	return ctx
+}
+
+// newRequest describes the call as Sentry's HTTP interface, gRPC calls being HTTP/2 POST requests
+func newRequest(ctx context.Context, fullMethod string) *raven.Http {
+	h := &raven.Http{
+		Method:  "POST",
+		URL:     fullMethod,
+		Headers: make(map[string]string),
+	}
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for key, values := range md {
+			h.Headers[key] = scrubMetadata(key, values)
+		}
+		if authority := md.Get(":authority"); len(authority) > 0 {
+			h.URL = "grpc://" + authority[0] + fullMethod
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		h.Env = map[string]string{"REMOTE_ADDR": p.Addr.String()}
+	}
+	return h
+}
+
+// scrubMetadata joins the values of a metadata key, masking secrets and binary values
+func scrubMetadata(key string, values []string) string {
+	key = strings.ToLower(key)
+	if strings.HasSuffix(key, "-bin") {
+		return "[binary]"
+	}
+	for _, secret := range metadataSecretKeys {
+		if strings.Contains(key, secret) {
+			return "********"
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// recoverPanic captures the panic and returns the error failing the call, unless Repanic is set
+func recoverPanic(ctx context.Context, opts *Options, fullMethod string, rval interface{}) error {
+	client := opts.client()
+
+	err, ok := rval.(error)
+	if !ok {
+		err = errors.New(fmt.Sprint(rval))
+	}
+	// Skip recoverPanic, the deferred function and runtime.gopanic
+	stacktrace := raven.NewStacktrace(3, 3, client.IncludePaths())
+	packet := raven.NewPacket(err.Error(), raven.NewException(err, stacktrace))
+	packet.Level = raven.FATAL
+	eventID, ch := client.CaptureCtx(ctx, packet, map[string]string{"grpc.method": fullMethod})
+
+	if opts != nil && opts.Repanic {
+		// Give the packet a chance to be sent before the process possibly dies
+		if eventID != "" {
+			<-ch
+		}
+		panic(rval)
+	}
+	return status.Errorf(codes.Internal, "%v", rval)
+}
+
+// recordCall adds an outgoing call to the scope of ctx, or to the client, and captures its error
+func recordCall(ctx context.Context, opts *Options, method, target string, start time.Time, err error) {
+	client := opts.client()
+	breadcrumb := &raven.Breadcrumb{
+		Type:     "http",
+		Category: "grpc",
+		Message:  method,
+		Level:    raven.INFO,
+		Data: map[string]interface{}{
+			"method":   method,
+			"target":   target,
+			"code":     status.Code(err).String(),
+			"duration": time.Since(start).String(),
+		},
+	}
+	if err != nil {
+		breadcrumb.Level = raven.ERROR
+	}
+	if scope := raven.ScopeFromContext(ctx); scope != nil {
+		scope.AddBreadcrumb(breadcrumb)
+	} else {
+		client.AddBreadcrumb(breadcrumb)
+	}
+
+	if opts.captures(err) {
+		client.CaptureErrorCtx(ctx, err, errorTags(method, err))
+	}
+}
+
+func errorTags(method string, err error) map[string]string {
+	return map[string]string{
+		"grpc.method": method,
+		"grpc.code":   status.Code(err).String(),
+	}
+}
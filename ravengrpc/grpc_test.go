@@ -0,0 +1,156 @@
+//go:build go1.19
+// +build go1.19
+
+package ravengrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/getsentry/raven-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type recordingTransport struct {
+	mu      sync.Mutex
+	packets []*raven.Packet
+}
+
+func (t *recordingTransport) Send(url, authHeader string, packet *raven.Packet) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.packets = append(t.packets, packet)
+	return nil
+}
+
+func newTestClient(t *testing.T) (*raven.Client, *recordingTransport) {
+	client, err := raven.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &recordingTransport{}
+	client.Transport = transport
+	return client, transport
+}
+
+func incomingContext() context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(
+		":authority", "users.internal:443",
+		"authorization", "Bearer secret",
+		"x-request-id", "42",
+	))
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 5000}})
+}
+
+func TestUnaryServerInterceptorRecoversPanics(t *testing.T) {
+	client, transport := newTestClient(t)
+	interceptor := UnaryServerInterceptor(&Options{Client: client})
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Users/Get"}
+
+	_, err := interceptor(incomingContext(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("nil user")
+	})
+	client.Wait()
+
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected an Internal error, got %v", err)
+	}
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the panic to be captured, got %d packets", len(transport.packets))
+	}
+
+	packet := transport.packets[0]
+	if packet.Culprit != "/api.Users/Get" || packet.Level != raven.FATAL {
+		t.Errorf("incorrect packet: got culprit %q at %q", packet.Culprit, packet.Level)
+	}
+	var request *raven.Http
+	for _, inter := range packet.Interfaces {
+		if h, ok := inter.(*raven.Http); ok {
+			request = h
+		}
+	}
+	if request == nil {
+		t.Fatal("expected the call to be attached as request")
+	}
+	if request.URL != "grpc://users.internal:443/api.Users/Get" || request.Env["REMOTE_ADDR"] != "10.0.0.1:5000" {
+		t.Errorf("incorrect request: got %+v", request)
+	}
+	if request.Headers["authorization"] != "********" || request.Headers["x-request-id"] != "42" {
+		t.Errorf("incorrect metadata: got %+v", request.Headers)
+	}
+}
+
+func TestUnaryServerInterceptorCapturesErrors(t *testing.T) {
+	client, transport := newTestClient(t)
+	interceptor := UnaryServerInterceptor(&Options{Client: client})
+	info := &grpc.UnaryServerInfo{FullMethod: "/api.Users/Get"}
+
+	for _, code := range []codes.Code{codes.NotFound, codes.Internal} {
+		interceptor(incomingContext(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, status.Error(code, "failed")
+		})
+	}
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected only the Internal error to be captured, got %d packets", len(transport.packets))
+	}
+	if packet := transport.packets[0]; packet.Culprit != "/api.Users/Get" {
+		t.Errorf("incorrect culprit: got %q", packet.Culprit)
+	}
+}
+
+func TestStreamServerInterceptorPropagatesScope(t *testing.T) {
+	client, _ := newTestClient(t)
+	interceptor := StreamServerInterceptor(&Options{Client: client})
+	info := &grpc.StreamServerInfo{FullMethod: "/api.Users/List"}
+
+	var scope *raven.Scope
+	interceptor(nil, &scopedServerStream{ctx: incomingContext()}, info, func(srv interface{}, ss grpc.ServerStream) error {
+		scope = raven.ScopeFromContext(ss.Context())
+		return nil
+	})
+	if scope == nil {
+		t.Error("expected the stream context to carry a scope")
+	}
+}
+
+func TestUnaryClientInterceptorRecordsCalls(t *testing.T) {
+	client, transport := newTestClient(t)
+	interceptor := UnaryClientInterceptor(&Options{Client: client})
+	cc, err := grpc.Dial("passthrough:///users.internal:443", grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cc.Close()
+
+	ctx := raven.WithScope(context.Background())
+	err = interceptor(ctx, "/api.Users/Get", nil, nil, cc, func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return errors.New("connection reset")
+	})
+	if err == nil {
+		t.Fatal("expected the error to be returned")
+	}
+
+	client.CaptureErrorCtx(ctx, err, nil)
+	client.Wait()
+	if len(transport.packets) != 2 {
+		t.Fatalf("expected the Unknown error to be captured, got %d packets", len(transport.packets))
+	}
+	for _, inter := range transport.packets[1].Interfaces {
+		if breadcrumbs, ok := inter.(*raven.Breadcrumbs); ok {
+			if b := breadcrumbs.Values[0]; b.Category != "grpc" || b.Data["code"] != "Unknown" || b.Level != raven.ERROR {
+				t.Errorf("incorrect breadcrumb: got %+v", b)
+			}
+			return
+		}
+	}
+	t.Error("expected the call to be recorded in the scope")
+}
@@ -0,0 +1,89 @@
+package raven
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultRequestIDHeaders are the headers reported as tags when RequestScoperOptions.RequestIDHeaders is nil
+var DefaultRequestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "X-Amzn-Trace-Id"}
+
+// RequestScoperOptions configures the middleware returned by RequestScoper
+type RequestScoperOptions struct {
+	// User returns the user sending the request, or nil when it is anonymous.
+	// The address of the client is reported as the user IP unless one is set.
+	User func(*http.Request) *User
+
+	// RequestIDHeaders lists the headers reported as tags, under their lower-cased
+	// name, when present in the request. Defaults to DefaultRequestIDHeaders.
+	RequestIDHeaders []string
+}
+
+// RequestScoper wraps a handler so that every request is handled with its own Scope,
+// carried by the request's context, holding the request, its user and their address,
+// its request ID tags and a breadcrumb recording its start. Events captured with the
+// *Ctx functions and the request's context, as well as by Recoverer, are reported
+// along with them.
+//
+//	http.Handle("/", raven.RequestScoper(mux, &raven.RequestScoperOptions{
+//		User: func(r *http.Request) *raven.User {
+//			return &raven.User{ID: sessionUserID(r)}
+//		},
+//	}))
+//
+//	func handler(w http.ResponseWriter, r *http.Request) {
+//		...
+//		raven.CaptureErrorCtx(r.Context(), err, nil)
+//	}
+func RequestScoper(handler http.Handler, opts *RequestScoperOptions) http.Handler {
+	if opts == nil {
+		opts = &RequestScoperOptions{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithScope(r.Context())
+		r = r.WithContext(ctx)
+		scope := ScopeFromContext(ctx)
+
+		h := NewHttp(r)
+		scope.SetHttp(h)
+		user := &User{}
+		if opts.User != nil {
+			if u := opts.User(r); u != nil {
+				copied := *u
+				user = &copied
+			}
+		}
+		if user.IP == "" {
+			user.IP = ClientIP(r)
+		}
+		scope.SetUser(user)
+		scope.SetTags(opts.requestIDTags(r))
+		scope.AddBreadcrumb(&Breadcrumb{
+			Type:     "http",
+			Category: "request",
+			Message:  r.Method + " " + r.URL.Path,
+			Level:    INFO,
+			Data: map[string]interface{}{
+				"method": r.Method,
+				"url":    h.URL,
+			},
+		})
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requestIDTags returns the request ID headers present in the request as tags
+func (opts *RequestScoperOptions) requestIDTags(r *http.Request) map[string]string {
+	headers := DefaultRequestIDHeaders
+	if opts.RequestIDHeaders != nil {
+		headers = opts.RequestIDHeaders
+	}
+	tags := make(map[string]string)
+	for _, header := range headers {
+		if value := r.Header.Get(header); value != "" {
+			tags[strings.ToLower(header)] = value
+		}
+	}
+	return tags
+}
@@ -0,0 +1,47 @@
+package raven
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestScoper(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := RequestScoper(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client.CaptureErrorCtx(r.Context(), errors.New("database is down"), nil)
+	}), &RequestScoperOptions{
+		User: func(r *http.Request) *User {
+			return &User{ID: r.Header.Get("X-User")}
+		},
+	})
+
+	req := httptest.NewRequest("GET", "http://example.com/users?id=1", nil)
+	req.Header.Set("X-User", "42")
+	req.Header.Set("X-Request-Id", "abc")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	client.Wait()
+
+	if ScopeFromContext(req.Context()) != nil {
+		t.Error("expected the scope not to leak to the original request")
+	}
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the error to be captured, got %d packets", len(transport.packets))
+	}
+	packet := transport.packets[0]
+	if h, _ := packetInterface(packet, "request").(*Http); h == nil || h.URL != "http://example.com/users" || h.Query != "id=1" {
+		t.Errorf("incorrect request: got %+v", h)
+	}
+	if user, _ := packetInterface(packet, "user").(*User); user == nil || user.ID != "42" || user.IP != "192.0.2.1" {
+		t.Errorf("incorrect user: got %+v", user)
+	}
+	if tags := packetTags(packet); tags["x-request-id"] != "abc" {
+		t.Errorf("expected the request ID to be tagged, got %v", tags)
+	}
+	breadcrumbs, _ := packetInterface(packet, "breadcrumbs").(*Breadcrumbs)
+	if breadcrumbs == nil || len(breadcrumbs.Values) != 1 || breadcrumbs.Values[0].Message != "GET /users" {
+		t.Errorf("expected the request start to be recorded, got %+v", breadcrumbs)
+	}
+}
@@ -0,0 +1,245 @@
+//go:build go1.8
+// +build go1.8
+
+package raven
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StatusRange is an inclusive range of HTTP status codes
+type StatusRange struct {
+	Min, Max int
+}
+
+// DefaultCaptureStatusRanges are the statuses captured when ResponseCapturerOptions.StatusRanges is nil
+var DefaultCaptureStatusRanges = []StatusRange{{500, 599}}
+
+// ResponseCapturerOptions configures the middleware returned by ResponseCapturer
+type ResponseCapturerOptions struct {
+	// Client captures the responses. Defaults to the default *Client.
+	Client *Client
+
+	// StatusRanges lists the statuses of the responses captured as events.
+	// Defaults to DefaultCaptureStatusRanges.
+	StatusRanges []StatusRange
+}
+
+func (opts *ResponseCapturerOptions) captures(status int) bool {
+	ranges := DefaultCaptureStatusRanges
+	if opts.StatusRanges != nil {
+		ranges = opts.StatusRanges
+	}
+	for _, r := range ranges {
+		if status >= r.Min && status <= r.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// ResponseCapturer wraps a handler so that its responses with a status within
+// the configured ranges, 5xx by default, are captured as events along with the
+// request, even when the handler does not panic. The error set with
+// SetRequestError while handling the request, if any, is reported as exception.
+// Responses of hijacked connections are never captured.
+//
+//	http.Handle("/", raven.Recoverer(raven.ResponseCapturer(mux, nil)))
+func ResponseCapturer(handler http.Handler, opts *ResponseCapturerOptions) http.Handler {
+	if opts == nil {
+		opts = &ResponseCapturerOptions{}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqErr := &requestError{}
+		r = r.WithContext(context.WithValue(r.Context(), requestErrorKey{}, reqErr))
+		sw := &statusWriter{ResponseWriter: w}
+
+		start := time.Now()
+		handler.ServeHTTP(wrapStatusWriter(sw), r)
+		duration := time.Since(start)
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if sw.hijacked || !opts.captures(status) {
+			return
+		}
+		opts.capture(r, status, duration, reqErr.get())
+	})
+}
+
+// capture sends the response as a message packet, or as an error packet when err is set
+func (opts *ResponseCapturerOptions) capture(r *http.Request, status int, duration time.Duration, err error) {
+	client := opts.Client
+	if client == nil {
+		client = DefaultClient
+	}
+	if client == nil {
+		return
+	}
+
+	var packet *Packet
+	if err != nil {
+		// The stacktrace of the middleware would be meaningless, only the one
+		// recorded by the error, if any, is reported
+		packet = NewPacketWithExtra(err.Error(), extractExtra(err), NewHttp(r))
+		packet.AddTags(extractTags(err))
+		applyErrorDecorations(packet, err)
+		packet.Interfaces = append(packet.Interfaces, NewExceptionChain(err, nil, 3, client.IncludePaths()))
+	} else {
+		message := fmt.Sprintf("%s %s: %d %s", r.Method, r.URL.Path, status, http.StatusText(status))
+		packet = NewPacket(message, NewHttp(r), &Message{message, nil})
+	}
+	if packet.Level == "" && status < 500 {
+		packet.Level = WARNING
+	}
+	packet.Extra["status_code"] = status
+	packet.Extra["duration"] = duration.String()
+
+	client.CaptureCtx(r.Context(), packet, map[string]string{"http.status_code": strconv.Itoa(status)})
+}
+
+type requestErrorKey struct{}
+
+// requestError holds the error set by the handler, which only sees a copy of the request
+type requestError struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (e *requestError) get() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// SetRequestError records err as the cause of the response to r, so that it is
+// reported if ResponseCapturer captures the response. It does nothing when r is
+// not handled by ResponseCapturer.
+func SetRequestError(r *http.Request, err error) {
+	e, ok := r.Context().Value(requestErrorKey{}).(*requestError)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.err = err
+}
+
+// statusWriter records the status of the response
+type statusWriter struct {
+	http.ResponseWriter
+	status   int
+	hijacked bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the wrapped writer, so that http.ResponseController can reach
+// the optional methods it implements
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *statusWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *statusWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// wrapStatusWriter returns a writer implementing the same optional interfaces
+// among http.Flusher, http.Hijacker and http.Pusher as the wrapped one
+func wrapStatusWriter(w *statusWriter) http.ResponseWriter {
+	_, flusher := w.ResponseWriter.(http.Flusher)
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+	_, pusher := w.ResponseWriter.(http.Pusher)
+
+	switch {
+	case flusher && hijacker && pusher:
+		return struct {
+			*statusWriter
+			http.Flusher
+			http.Hijacker
+			http.Pusher
+		}{w, flushFunc(w.flush), hijackFunc(w.hijack), pushFunc(w.push)}
+	case flusher && hijacker:
+		return struct {
+			*statusWriter
+			http.Flusher
+			http.Hijacker
+		}{w, flushFunc(w.flush), hijackFunc(w.hijack)}
+	case flusher && pusher:
+		return struct {
+			*statusWriter
+			http.Flusher
+			http.Pusher
+		}{w, flushFunc(w.flush), pushFunc(w.push)}
+	case hijacker && pusher:
+		return struct {
+			*statusWriter
+			http.Hijacker
+			http.Pusher
+		}{w, hijackFunc(w.hijack), pushFunc(w.push)}
+	case flusher:
+		return struct {
+			*statusWriter
+			http.Flusher
+		}{w, flushFunc(w.flush)}
+	case hijacker:
+		return struct {
+			*statusWriter
+			http.Hijacker
+		}{w, hijackFunc(w.hijack)}
+	case pusher:
+		return struct {
+			*statusWriter
+			http.Pusher
+		}{w, pushFunc(w.push)}
+	}
+	return w
+}
+
+type flushFunc func()
+
+func (f flushFunc) Flush() { f() }
+
+type hijackFunc func() (net.Conn, *bufio.ReadWriter, error)
+
+func (f hijackFunc) Hijack() (net.Conn, *bufio.ReadWriter, error) { return f() }
+
+type pushFunc func(string, *http.PushOptions) error
+
+func (f pushFunc) Push(target string, opts *http.PushOptions) error { return f(target, opts) }
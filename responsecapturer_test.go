@@ -0,0 +1,109 @@
+//go:build go1.8
+// +build go1.8
+
+package raven
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseCapturerCapturesServerErrors(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := ResponseCapturer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/found":
+			w.Write([]byte("ok"))
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			SetRequestError(r, errors.New("database is down"))
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}), &ResponseCapturerOptions{Client: client})
+
+	for _, path := range []string{"/found", "/missing", "/users"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com"+path, nil))
+	}
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected only the 5xx response to be captured, got %d packets", len(transport.packets))
+	}
+	packet := transport.packets[0]
+	if packet.Message != "database is down" || packet.Extra["status_code"] != http.StatusServiceUnavailable {
+		t.Errorf("incorrect packet: got %q with extra %v", packet.Message, packet.Extra)
+	}
+	if packetTags(packet)["http.status_code"] != "503" {
+		t.Errorf("incorrect tags: got %v", packetTags(packet))
+	}
+	if ex, ok := packetInterface(packet, "exception").(*Exception); !ok {
+		t.Error("expected the request error to be attached as exception")
+	} else if ex.Stacktrace != nil {
+		t.Errorf("expected no stacktrace for an error lacking one, got %+v", ex.Stacktrace)
+	}
+	if h, _ := packetInterface(packet, "request").(*Http); h == nil || h.URL != "http://example.com/users" {
+		t.Errorf("incorrect request: got %+v", h)
+	}
+}
+
+func TestResponseCapturerStatusRanges(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := ResponseCapturer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}), &ResponseCapturerOptions{Client: client, StatusRanges: []StatusRange{{404, 404}}})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/users", nil))
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected the 404 response to be captured, got %d packets", len(transport.packets))
+	}
+	if packet := transport.packets[0]; packet.Message != "GET /users: 404 Not Found" || packet.Level != WARNING {
+		t.Errorf("incorrect packet: got %q at %q", packet.Message, packet.Level)
+	}
+}
+
+func TestResponseCapturerPreservesInterfaces(t *testing.T) {
+	var flusher, hijacker, pusher bool
+	handler := ResponseCapturer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flusher = w.(http.Flusher)
+		_, hijacker = w.(http.Hijacker)
+		_, pusher = w.(http.Pusher)
+	}), &ResponseCapturerOptions{Client: newTestClient(&recordingTransport{})})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/", nil))
+	if !flusher || hijacker || pusher {
+		t.Errorf("expected only http.Flusher to be implemented, got flusher=%v hijacker=%v pusher=%v", flusher, hijacker, pusher)
+	}
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal("request failed:", err)
+	}
+	res.Body.Close()
+	if !flusher || !hijacker || pusher {
+		t.Errorf("expected http.Flusher and http.Hijacker to be implemented, got flusher=%v hijacker=%v pusher=%v", flusher, hijacker, pusher)
+	}
+}
+
+func TestResponseCapturerUnwrapsWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	var unwrapped http.ResponseWriter
+	handler := ResponseCapturer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, ok := w.(interface{ Unwrap() http.ResponseWriter }); ok {
+			unwrapped = u.Unwrap()
+		}
+	}), &ResponseCapturerOptions{Client: newTestClient(&recordingTransport{})})
+
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "http://example.com/", nil))
+	if unwrapped != recorder {
+		t.Errorf("expected the writer to unwrap to the original one, got %v", unwrapped)
+	}
+}
@@ -0,0 +1,108 @@
+package raven
+
+import (
+	mrand "math/rand"
+	"time"
+)
+
+// RetryPolicy describes how a Transport retries a delivery that failed because of
+// a network error or a retryable HTTP status code.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values lower than 1 are treated as 1.
+	MaxAttempts int
+
+	// BaseBackoff is the delay before the first retry. Every following retry doubles it.
+	BaseBackoff time.Duration
+
+	// MaxBackoff caps the delay between two attempts. Zero means no cap.
+	MaxBackoff time.Duration
+
+	// Jitter is the fraction (between 0 and 1) of the delay that is randomized
+	// to avoid synchronized retries from many processes.
+	Jitter float64
+
+	// RetryableStatusCodes lists HTTP status codes that are worth retrying.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy is a sensible policy for transient Sentry or proxy failures.
+// It is not enabled by default, use Client.SetRetryPolicy to opt in.
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts:          3,
+	BaseBackoff:          500 * time.Millisecond,
+	MaxBackoff:           5 * time.Second,
+	Jitter:               0.2,
+	RetryableStatusCodes: []int{500, 502, 503, 504},
+}
+
+// RetryPolicyTransport is implemented by transports which can retry failed deliveries.
+// Custom transports can implement it to be configured through Client.SetRetryPolicy.
+type RetryPolicyTransport interface {
+	Transport
+	SetRetryPolicy(policy *RetryPolicy)
+}
+
+// Attempts returns the total number of attempts allowed by the policy.
+// A nil policy allows a single attempt.
+func (p *RetryPolicy) Attempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// Backoff returns the delay to wait after the given failed attempt (starting at 1).
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if p == nil || attempt < 1 {
+		return 0
+	}
+
+	backoff := p.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff -= time.Duration(float64(backoff) * jitter * mrand.Float64())
+	}
+	return backoff
+}
+
+// IsRetryableStatus reports whether a response with the given status code should be retried
+func (p *RetryPolicy) IsRetryableStatus(code int) bool {
+	if p == nil {
+		return false
+	}
+	for _, c := range p.RetryableStatusCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRetryPolicy configures how the client's transport retries failed deliveries.
+// Passing nil disables retries. It returns ErrRetryNotSupported when the
+// transport does not implement RetryPolicyTransport.
+func (client *Client) SetRetryPolicy(policy *RetryPolicy) error {
+	t, ok := client.Transport.(RetryPolicyTransport)
+	if !ok {
+		return ErrRetryNotSupported
+	}
+	t.SetRetryPolicy(policy)
+	return nil
+}
+
+// SetRetryPolicy configures the retry policy of the default *Client's transport
+func SetRetryPolicy(policy *RetryPolicy) error { return DefaultClient.SetRetryPolicy(policy) }
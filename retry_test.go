@@ -0,0 +1,148 @@
+package raven
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyAttempts(t *testing.T) {
+	var nilPolicy *RetryPolicy
+	if nilPolicy.Attempts() != 1 {
+		t.Errorf("nil policy should allow a single attempt, got %d", nilPolicy.Attempts())
+	}
+	if (&RetryPolicy{}).Attempts() != 1 {
+		t.Errorf("zero policy should allow a single attempt")
+	}
+	if (&RetryPolicy{MaxAttempts: 4}).Attempts() != 4 {
+		t.Errorf("incorrect number of attempts")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	testCases := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{0, 0},
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+
+	for _, test := range testCases {
+		if actual := policy.Backoff(test.attempt); actual != test.expected {
+			t.Errorf("incorrect backoff for attempt %d: got %s, want %s", test.attempt, actual, test.expected)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := &RetryPolicy{BaseBackoff: 100 * time.Millisecond, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(1)
+		if backoff < 50*time.Millisecond || backoff > 100*time.Millisecond {
+			t.Fatalf("backoff %s outside of jitter range", backoff)
+		}
+	}
+}
+
+func TestHTTPTransportRetriesRetryableStatus(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := &HTTPTransport{Client: &http.Client{}}
+	transport.SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:          3,
+		BaseBackoff:          time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	})
+
+	if err := transport.Send(server.URL, "", NewPacket("retry")); err != nil {
+		t.Errorf("expected delivery to succeed after retries: %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("incorrect number of requests: got %d, want 3", requests)
+	}
+}
+
+func TestHTTPTransportDoesNotRetryOtherStatus(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	transport := &HTTPTransport{Client: &http.Client{}}
+	transport.SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:          3,
+		BaseBackoff:          time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	})
+
+	if err := transport.Send(server.URL, "", NewPacket("retry")); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if requests != 1 {
+		t.Errorf("incorrect number of requests: got %d, want 1", requests)
+	}
+}
+
+func TestHTTPTransportGivesUpAfterMaxAttempts(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	transport := &HTTPTransport{Client: &http.Client{}}
+	transport.SetRetryPolicy(&RetryPolicy{
+		MaxAttempts:          2,
+		BaseBackoff:          time.Millisecond,
+		RetryableStatusCodes: []int{http.StatusBadGateway},
+	})
+
+	if err := transport.Send(server.URL, "", NewPacket("retry")); err == nil {
+		t.Error("expected delivery to fail")
+	}
+	if requests != 2 {
+		t.Errorf("incorrect number of requests: got %d, want 2", requests)
+	}
+}
+
+type noRetryTransport struct{}
+
+func (t *noRetryTransport) Send(url, authHeader string, packet *Packet) error { return nil }
+
+func TestSetRetryPolicyUnsupportedTransport(t *testing.T) {
+	client := &Client{Transport: &noRetryTransport{}}
+	if err := client.SetRetryPolicy(DefaultRetryPolicy); err != ErrRetryNotSupported {
+		t.Errorf("expected ErrRetryNotSupported, got %v", err)
+	}
+
+	transport := &HTTPTransport{}
+	client = &Client{Transport: transport}
+	if err := client.SetRetryPolicy(DefaultRetryPolicy); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if transport.RetryPolicy != DefaultRetryPolicy {
+		t.Error("retry policy was not set on the transport")
+	}
+}
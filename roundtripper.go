@@ -0,0 +1,99 @@
+package raven
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RoundTripper is an http.RoundTripper recording every outbound request as a breadcrumb,
+// in the scope carried by the request's context if any, or else in the client's one.
+// Secrets in the queries are masked by the Scrubber of the client, see SetScrubber.
+type RoundTripper struct {
+	// Base performs the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+
+	// Client records the breadcrumbs and captures the events. Defaults to the default *Client.
+	Client *Client
+
+	// CaptureServerErrors captures responses with a 5xx status as events
+	CaptureServerErrors bool
+
+	// CaptureErrors captures the errors returned by Base as events
+	CaptureErrors bool
+}
+
+// NewRoundTripper returns a RoundTripper performing the requests with base and
+// recording them with client. Either may be nil to use the defaults.
+func NewRoundTripper(base http.RoundTripper, client *Client) *RoundTripper {
+	return &RoundTripper{Base: base, Client: client}
+}
+
+// RoundTrip performs the request with Base and records it
+func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := rt.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client := rt.Client
+	if client == nil {
+		client = DefaultClient
+	}
+
+	start := time.Now()
+	res, err := base.RoundTrip(req)
+	duration := time.Since(start)
+
+	url := sanitizedURL(req, client.getScrubber())
+	breadcrumb := &Breadcrumb{
+		Type:     "http",
+		Category: "http",
+		Level:    INFO,
+		Data: map[string]interface{}{
+			"method":   req.Method,
+			"url":      url,
+			"duration": duration.String(),
+		},
+	}
+	switch {
+	case err != nil:
+		breadcrumb.Level = ERROR
+		breadcrumb.Data["reason"] = err.Error()
+	case res.StatusCode >= 500:
+		breadcrumb.Level = ERROR
+	case res.StatusCode >= 400:
+		breadcrumb.Level = WARNING
+	}
+	if res != nil {
+		breadcrumb.Data["status_code"] = res.StatusCode
+		breadcrumb.Data["reason"] = res.Status
+	}
+
+	ctx := req.Context()
+	if scope := ScopeFromContext(ctx); scope != nil {
+		scope.AddBreadcrumb(breadcrumb)
+	} else {
+		client.AddBreadcrumb(breadcrumb)
+	}
+
+	tags := map[string]string{"http.method": req.Method, "http.host": req.URL.Host}
+	switch {
+	case err != nil && rt.CaptureErrors:
+		client.CaptureErrorCtx(ctx, WrapWithExtra(err, map[string]interface{}{"url": url}), tags)
+	case err == nil && res.StatusCode >= 500 && rt.CaptureServerErrors:
+		client.CaptureMessageCtx(ctx, fmt.Sprintf("%s %s: %s", req.Method, url, res.Status), tags)
+	}
+
+	return res, err
+}
+
+// sanitizedURL returns the URL of the request without credentials, and with the
+// secrets of the query masked by scrubber unless it is nil
+func sanitizedURL(req *http.Request, scrubber *Scrubber) string {
+	u := *req.URL
+	u.User = nil
+	if u.RawQuery != "" && scrubber != nil {
+		u.RawQuery = scrubber.scrubQueryString(u.RawQuery)
+	}
+	return u.String()
+}
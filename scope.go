@@ -0,0 +1,336 @@
+package raven
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Scope holds the user, request, query, tags, extra and breadcrumbs attached to captured packets.
+// Every Client has its own scope, updated with SetUserContext and friends. Per request
+// scopes are carried by a context.Context, see WithScope, and merged into the packets
+// captured with CaptureErrorCtx and CaptureMessageCtx.
+//
+// It is safe to use a Scope concurrently.
+type Scope struct {
+	mu          sync.RWMutex
+	user        *User
+	http        *Http
+	query       *Query
+	culprit     string
+	tags        map[string]string
+	extra       Extra
+	breadcrumbs *breadcrumbBuffer
+}
+
+// NewScope constructs an empty scope
+func NewScope() *Scope {
+	return &Scope{breadcrumbs: newBreadcrumbBuffer(MaxBreadcrumbs)}
+}
+
+// SetUser sets the user reported with packets captured in this scope
+func (s *Scope) SetUser(u *User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+// SetHttp sets the request reported with packets captured in this scope
+func (s *Scope) SetHttp(h *Http) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.http = h
+}
+
+// SetQuery sets the database query reported with packets captured in this scope
+func (s *Scope) SetQuery(q *Query) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.query = q
+}
+
+// SetCulprit sets the culprit of packets captured in this scope, such as the name of
+// the request handler, instead of the one derived from their stacktrace
+func (s *Scope) SetCulprit(culprit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.culprit = culprit
+}
+
+// SetTags adds tags to the scope, overriding existing ones with the same key
+func (s *Scope) SetTags(t map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tags == nil {
+		s.tags = make(map[string]string)
+	}
+	for k, v := range t {
+		s.tags[k] = v
+	}
+}
+
+// SetExtra adds extra data to the scope, overriding existing ones with the same key
+func (s *Scope) SetExtra(e Extra) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.extra == nil {
+		s.extra = Extra{}
+	}
+	for k, v := range e {
+		s.extra[k] = v
+	}
+}
+
+// AddBreadcrumb records a breadcrumb in the scope
+func (s *Scope) AddBreadcrumb(b *Breadcrumb) {
+	if b == nil {
+		return
+	}
+	if time.Time(b.Timestamp).IsZero() {
+		b.Timestamp = Timestamp(time.Now())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.breadcrumbs == nil {
+		s.breadcrumbs = newBreadcrumbBuffer(MaxBreadcrumbs)
+	}
+	s.breadcrumbs.add(b)
+}
+
+// SetMaxBreadcrumbs updates how many breadcrumbs the scope keeps
+func (s *Scope) SetMaxBreadcrumbs(max int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.breadcrumbs == nil {
+		s.breadcrumbs = newBreadcrumbBuffer(max)
+	} else {
+		s.breadcrumbs.setMax(max)
+	}
+}
+
+// Clear removes user, request, query, tags, extra and breadcrumbs from the scope
+func (s *Scope) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = nil
+	s.http = nil
+	s.query = nil
+	s.culprit = ""
+	s.tags = nil
+	s.extra = nil
+	if s.breadcrumbs != nil {
+		s.breadcrumbs.clear()
+	}
+}
+
+// Clone returns a copy of the scope which can be modified independently
+func (s *Scope) Clone() *Scope {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	clone := &Scope{
+		user:    s.user,
+		http:    s.http,
+		query:   s.query,
+		culprit: s.culprit,
+	}
+	if s.tags != nil {
+		clone.tags = make(map[string]string, len(s.tags))
+		for k, v := range s.tags {
+			clone.tags[k] = v
+		}
+	}
+	if s.extra != nil {
+		clone.extra = make(Extra, len(s.extra))
+		for k, v := range s.extra {
+			clone.extra[k] = v
+		}
+	}
+	if s.breadcrumbs != nil {
+		clone.breadcrumbs = newBreadcrumbBuffer(s.breadcrumbs.max)
+		for _, b := range s.breadcrumbs.snapshot() {
+			clone.breadcrumbs.add(b)
+		}
+	}
+	return clone
+}
+
+// merge overrides the scope with the values set in other. Breadcrumbs of
+// both scopes are kept, sorted by time.
+func (s *Scope) merge(other *Scope) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if other.user != nil {
+		s.user = other.user
+	}
+	if other.http != nil {
+		s.http = other.http
+	}
+	if other.query != nil {
+		s.query = other.query
+	}
+	if other.culprit != "" {
+		s.culprit = other.culprit
+	}
+	if len(other.tags) > 0 && s.tags == nil {
+		s.tags = make(map[string]string, len(other.tags))
+	}
+	for k, v := range other.tags {
+		s.tags[k] = v
+	}
+	if len(other.extra) > 0 && s.extra == nil {
+		s.extra = make(Extra, len(other.extra))
+	}
+	for k, v := range other.extra {
+		s.extra[k] = v
+	}
+
+	if other.breadcrumbs == nil {
+		return
+	}
+	var values []*Breadcrumb
+	max := other.breadcrumbs.max
+	if s.breadcrumbs != nil {
+		values = s.breadcrumbs.snapshot()
+		if s.breadcrumbs.max > max {
+			max = s.breadcrumbs.max
+		}
+	}
+	values = append(values, other.breadcrumbs.snapshot()...)
+	sort.Stable(byTimestamp(values))
+
+	s.breadcrumbs = newBreadcrumbBuffer(max)
+	for _, b := range values {
+		s.breadcrumbs.add(b)
+	}
+}
+
+// Return a list of interfaces to be used in appending with the rest
+func (s *Scope) interfaces() []Interface {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var interfaces []Interface
+	if s.user != nil {
+		interfaces = append(interfaces, s.user)
+	}
+	if s.http != nil {
+		interfaces = append(interfaces, s.http)
+	}
+	if s.query != nil {
+		interfaces = append(interfaces, s.query)
+	}
+	if s.breadcrumbs != nil {
+		if values := s.breadcrumbs.snapshot(); len(values) > 0 {
+			interfaces = append(interfaces, &Breadcrumbs{Values: values})
+		}
+	}
+	return interfaces
+}
+
+// snapshotTags returns a copy of the scope's tags
+func (s *Scope) snapshotTags() map[string]string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tags := make(map[string]string, len(s.tags))
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// applyTo adds the scope's tags, extra and culprit to the packet. Extra and
+// culprit already set on the packet are not overridden.
+func (s *Scope) applyTo(packet *Packet) {
+	if s == nil {
+		return
+	}
+	packet.AddTags(s.snapshotTags())
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if packet.Culprit == "" {
+		packet.Culprit = s.culprit
+	}
+	if len(s.extra) > 0 && packet.Extra == nil {
+		packet.Extra = Extra{}
+	}
+	for k, v := range s.extra {
+		if _, ok := packet.Extra[k]; !ok {
+			packet.Extra[k] = v
+		}
+	}
+}
+
+type byTimestamp []*Breadcrumb
+
+func (b byTimestamp) Len() int      { return len(b) }
+func (b byTimestamp) Swap(i, j int) { b[i], b[j] = b[j], b[i] }
+func (b byTimestamp) Less(i, j int) bool {
+	return time.Time(b[i].Timestamp).Before(time.Time(b[j].Timestamp))
+}
+
+type scopeKey struct{}
+
+// WithScope returns a copy of ctx carrying a new scope. When ctx already carries
+// a scope the new one starts as a clone of it, so that changes made down the
+// call chain don't leak to the parent.
+func WithScope(ctx context.Context) context.Context {
+	scope := ScopeFromContext(ctx)
+	if scope == nil {
+		scope = NewScope()
+	} else {
+		scope = scope.Clone()
+	}
+	return context.WithValue(ctx, scopeKey{}, scope)
+}
+
+// ScopeFromContext returns the scope carried by ctx, or nil
+func ScopeFromContext(ctx context.Context) *Scope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(scopeKey{}).(*Scope)
+	return scope
+}
+
+// CaptureCtx is identical to Capture, except that the client's scope and the one
+// carried by ctx, if any, are merged into the packet. Interfaces already set on
+// the packet take precedence.
+func (client *Client) CaptureCtx(ctx context.Context, packet *Packet, captureTags map[string]string) (eventID string, ch chan error) {
+	if client != nil && packet != nil {
+		packet.Interfaces = append(client.scopeInterfaces(ctx), packet.Interfaces...)
+		ScopeFromContext(ctx).applyTo(packet)
+	}
+	return client.Capture(packet, captureTags)
+}
+
+// CaptureCtx sends a packet to the default *Client, merging its scope and the one carried by ctx
+func CaptureCtx(ctx context.Context, packet *Packet, captureTags map[string]string) (eventID string, ch chan error) {
+	return DefaultClient.CaptureCtx(ctx, packet, captureTags)
+}
+
+// scopeInterfaces returns the interfaces of the client's scope, overridden by
+// the ones of the scope carried by ctx
+func (client *Client) scopeInterfaces(ctx context.Context) []Interface {
+	scope := ScopeFromContext(ctx)
+	if scope == nil {
+		return client.contextInterfaces()
+	}
+
+	merged := client.context.Clone()
+	merged.merge(scope)
+	return merged.interfaces()
+}
@@ -0,0 +1,147 @@
+package raven
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func packetInterface(packet *Packet, class string) Interface {
+	var found Interface
+	for _, inter := range packet.Interfaces {
+		if inter != nil && inter.Class() == class {
+			found = inter
+		}
+	}
+	return found
+}
+
+func packetTags(packet *Packet) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range packet.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags
+}
+
+func TestScopeFromContext(t *testing.T) {
+	if scope := ScopeFromContext(context.Background()); scope != nil {
+		t.Error("expected no scope in background context")
+	}
+
+	ctx := WithScope(context.Background())
+	parent := ScopeFromContext(ctx)
+	if parent == nil {
+		t.Fatal("expected a scope")
+	}
+	parent.SetTags(map[string]string{"foo": "bar"})
+
+	child := ScopeFromContext(WithScope(ctx))
+	child.SetTags(map[string]string{"foo": "baz"})
+
+	if tags := parent.snapshotTags(); tags["foo"] != "bar" {
+		t.Errorf("child scope leaked into its parent: %v", tags)
+	}
+	if tags := child.snapshotTags(); tags["foo"] != "baz" {
+		t.Errorf("incorrect child tags: %v", tags)
+	}
+}
+
+func TestScopeMergeBreadcrumbs(t *testing.T) {
+	now := time.Now()
+	base := NewScope()
+	base.AddBreadcrumb(&Breadcrumb{Message: "first", Timestamp: Timestamp(now)})
+	base.AddBreadcrumb(&Breadcrumb{Message: "third", Timestamp: Timestamp(now.Add(2 * time.Second))})
+	other := NewScope()
+	other.AddBreadcrumb(&Breadcrumb{Message: "second", Timestamp: Timestamp(now.Add(time.Second))})
+
+	base.merge(other)
+
+	expected := []string{"first", "second", "third"}
+	if actual := breadcrumbMessages(base.breadcrumbs.snapshot()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("incorrect breadcrumbs: got %v, want %v", actual, expected)
+	}
+}
+
+func TestCaptureErrorCtxIsolatesRequests(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetUserContext(&User{ID: "default"})
+	client.SetTagsContext(map[string]string{"client": "tag"})
+
+	var wg sync.WaitGroup
+	for _, id := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ctx := WithScope(context.Background())
+			scope := ScopeFromContext(ctx)
+			scope.SetUser(&User{ID: id})
+			scope.SetTags(map[string]string{"request": id})
+			scope.SetExtra(Extra{"request": id})
+			client.CaptureErrorCtx(ctx, errors.New(id), nil)
+		}(id)
+	}
+	wg.Wait()
+	client.Wait()
+
+	if len(transport.packets) != 2 {
+		t.Fatalf("expected 2 packets, got %d", len(transport.packets))
+	}
+	for _, packet := range transport.packets {
+		user := packetInterface(packet, "user").(*User)
+		if user.ID != packet.Message {
+			t.Errorf("packet %q reported with the wrong user %q", packet.Message, user.ID)
+		}
+		tags := packetTags(packet)
+		if tags["request"] != packet.Message || tags["client"] != "tag" {
+			t.Errorf("packet %q reported with the wrong tags %v", packet.Message, tags)
+		}
+		if packet.Extra["request"] != packet.Message {
+			t.Errorf("packet %q reported with the wrong extra %v", packet.Message, packet.Extra)
+		}
+	}
+}
+
+func TestCaptureMessageCtxWithoutScope(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetUserContext(&User{ID: "default"})
+
+	client.CaptureMessageCtx(context.Background(), "message", nil)
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected a single packet, got %d", len(transport.packets))
+	}
+	if user := packetInterface(transport.packets[0], "user").(*User); user.ID != "default" {
+		t.Errorf("expected the client's user, got %q", user.ID)
+	}
+}
+
+func TestCaptureCtxMergesScope(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetUserContext(&User{ID: "client"})
+
+	ctx := WithScope(context.Background())
+	ScopeFromContext(ctx).SetCulprit("/api.Users/Get")
+	ScopeFromContext(ctx).SetTags(map[string]string{"request": "42"})
+
+	packet := NewPacket("captured", &User{ID: "packet"})
+	_, ch := client.CaptureCtx(ctx, packet, nil)
+	<-ch
+
+	if packet.Culprit != "/api.Users/Get" {
+		t.Errorf("expected the scope culprit, got %q", packet.Culprit)
+	}
+	if user, ok := packetInterface(packet, "user").(*User); !ok || user.ID != "packet" {
+		t.Errorf("expected the packet interfaces to take precedence, got %+v", packetInterface(packet, "user"))
+	}
+	if tags := packetTags(packet); tags["request"] != "42" {
+		t.Errorf("expected the scope tags, got %+v", tags)
+	}
+}
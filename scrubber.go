@@ -0,0 +1,339 @@
+package raven
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// scrubbedValue replaces the sensitive values
+const scrubbedValue = "********"
+
+// DefaultScrubKeys are the key patterns of the Scrubber used by default
+var DefaultScrubKeys = []string{
+	"password", "passwd", "passphrase", "secret", "token", "auth", "api_key", "apikey", "api-key",
+	"session", "csrf", "xsrf", "credential", "private_key",
+}
+
+// Headers revealing the address of the client, which are only sent along with default PII
+var clientAddrHeaders = []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded"}
+
+var defaultScrubber = NewScrubber(DefaultScrubKeys)
+
+var (
+	// "key": value pairs of JSON documents, including truncated ones whose last string is unterminated
+	jsonFieldPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"\s*:\s*("(?:[^"\\]|\\.)*"?|[^,}\]\s]*)`)
+	// key=value pairs of forms, logfmt and similar formats
+	keyValuePattern = regexp.MustCompile(`([\w.\-\[\]]+)=([^&\s;,]*)`)
+)
+
+// Scrubber masks the sensitive values of packets: the ones of headers, cookies,
+// query parameters and request data, extra and tags whose key contains one of its
+// patterns, regardless of case. Cookies are masked one by one.
+//
+// Every Client scrubs the packets it captures with a Scrubber using DefaultScrubKeys,
+// unless another one is set with SetScrubber.
+type Scrubber struct {
+	keys []string
+}
+
+// NewScrubber returns a Scrubber masking the values of the keys containing one of the patterns
+func NewScrubber(keys []string) *Scrubber {
+	s := &Scrubber{keys: make([]string, len(keys))}
+	for i, key := range keys {
+		s.keys[i] = strings.ToLower(key)
+	}
+	return s
+}
+
+// Scrub masks the sensitive values of the packet and returns it. Interfaces and
+// extra are replaced by scrubbed copies, so that the ones shared with other
+// packets, such as the interfaces of a Scope, are left untouched. It can be used
+// as an EventProcessor.
+func (s *Scrubber) Scrub(packet *Packet) *Packet {
+	if s == nil || packet == nil {
+		return packet
+	}
+
+	interfaces := make([]Interface, len(packet.Interfaces))
+	for i, inter := range packet.Interfaces {
+		if h, ok := inter.(*Http); ok && h != nil {
+			inter = s.scrubHttp(h)
+		}
+		interfaces[i] = inter
+	}
+	packet.Interfaces = interfaces
+
+	if packet.Extra != nil {
+		packet.Extra = s.scrubValue(packet.Extra).(Extra)
+	}
+
+	if len(packet.Tags) > 0 {
+		tags := make(Tags, len(packet.Tags))
+		for i, tag := range packet.Tags {
+			if s.sensitive(tag.Key) {
+				tag.Value = scrubbedValue
+			}
+			tags[i] = tag
+		}
+		packet.Tags = tags
+	}
+	return packet
+}
+
+// sensitive reports whether the value of key must be masked
+func (s *Scrubber) sensitive(key string) bool {
+	key = strings.ToLower(key)
+	for _, pattern := range s.keys {
+		if strings.Contains(key, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scrubber) scrubHttp(h *Http) *Http {
+	scrubbed := *h
+	scrubbed.Query = s.scrubQueryString(h.Query)
+	scrubbed.Cookies = s.scrubCookies(h.Cookies)
+
+	var contentType string
+	if h.Headers != nil {
+		scrubbed.Headers = make(map[string]string, len(h.Headers))
+		for key, value := range h.Headers {
+			switch {
+			case strings.EqualFold(key, "Cookie"):
+				value = s.scrubCookies(value)
+			case s.sensitive(key):
+				value = scrubbedValue
+			case strings.EqualFold(key, "Content-Type"):
+				contentType = value
+			}
+			scrubbed.Headers[key] = value
+		}
+	}
+
+	switch data := h.Data.(type) {
+	case map[string]string:
+		scrubbed.Data = s.scrubValue(data)
+	case string:
+		scrubbed.Data = s.scrubBody(data, contentType)
+	default:
+		scrubbed.Data = s.scrubValue(h.Data)
+	}
+	return &scrubbed
+}
+
+// scrubValues masks the sensitive values in place and returns them
+func (s *Scrubber) scrubValues(values url.Values) url.Values {
+	for key := range values {
+		if s.sensitive(key) {
+			values[key] = []string{scrubbedValue}
+		}
+	}
+	return values
+}
+
+// scrubQueryString masks the sensitive values of an encoded query, which is returned
+// as is when there are none
+func (s *Scrubber) scrubQueryString(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	for key := range values {
+		if s.sensitive(key) {
+			return s.scrubValues(values).Encode()
+		}
+	}
+	return query
+}
+
+// scrubCookies masks the sensitive cookies of a Cookie header
+func (s *Scrubber) scrubCookies(header string) string {
+	if header == "" {
+		return header
+	}
+	cookies := strings.Split(header, ";")
+	for i, cookie := range cookies {
+		name := strings.TrimSpace(cookie)
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			name = name[:eq]
+		}
+		if s.sensitive(name) {
+			cookies[i] = name + "=" + scrubbedValue
+		} else {
+			cookies[i] = strings.TrimSpace(cookie)
+		}
+	}
+	return strings.Join(cookies, "; ")
+}
+
+// scrubBody masks the sensitive fields of form and JSON bodies. Multipart bodies are
+// dropped, and the sensitive "key":"value" and key=value pairs of other bodies, such
+// as truncated ones which can't be parsed, are masked.
+func (s *Scrubber) scrubBody(body, contentType string) string {
+	switch {
+	case strings.HasPrefix(contentType, "multipart/"):
+		return scrubbedValue
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		if _, err := url.ParseQuery(body); err == nil {
+			return s.scrubQueryString(body)
+		}
+	case strings.HasPrefix(strings.TrimSpace(body), "{"):
+		var fields map[string]interface{}
+		if json.Unmarshal([]byte(body), &fields) == nil {
+			if scrubbed, err := json.Marshal(s.scrubValue(fields)); err == nil {
+				return string(scrubbed)
+			}
+		}
+	}
+
+	body = jsonFieldPattern.ReplaceAllStringFunc(body, func(field string) string {
+		m := jsonFieldPattern.FindStringSubmatch(field)
+		if !s.sensitive(m[1]) {
+			return field
+		}
+		return m[0][:len(m[0])-len(m[2])] + `"` + scrubbedValue + `"`
+	})
+	return keyValuePattern.ReplaceAllStringFunc(body, func(pair string) string {
+		m := keyValuePattern.FindStringSubmatch(pair)
+		if !s.sensitive(m[1]) {
+			return pair
+		}
+		return m[1] + "=" + scrubbedValue
+	})
+}
+
+// scrubValue returns a copy of maps and slices with the values of sensitive keys masked
+func (s *Scrubber) scrubValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case Extra:
+		scrubbed := make(Extra, len(v))
+		for key, value := range v {
+			scrubbed[key] = s.scrubField(key, value)
+		}
+		return scrubbed
+	case map[string]interface{}:
+		scrubbed := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			scrubbed[key] = s.scrubField(key, value)
+		}
+		return scrubbed
+	case map[string]string:
+		scrubbed := make(map[string]string, len(v))
+		for key, value := range v {
+			if s.sensitive(key) {
+				value = scrubbedValue
+			}
+			scrubbed[key] = value
+		}
+		return scrubbed
+	case []interface{}:
+		scrubbed := make([]interface{}, len(v))
+		for i, value := range v {
+			scrubbed[i] = s.scrubValue(value)
+		}
+		return scrubbed
+	}
+	return value
+}
+
+func (s *Scrubber) scrubField(key string, value interface{}) interface{} {
+	if s.sensitive(key) {
+		return scrubbedValue
+	}
+	return s.scrubValue(value)
+}
+
+// SetScrubber sets the Scrubber masking the sensitive values of captured packets.
+// Passing nil disables scrubbing.
+func (client *Client) SetScrubber(scrubber *Scrubber) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.scrubber = scrubber
+}
+
+// getScrubber returns the Scrubber of the client, nil when scrubbing is disabled
+func (client *Client) getScrubber() *Scrubber {
+	client.mu.RLock()
+	defer client.mu.RUnlock()
+	return client.scrubber
+}
+
+// SetSendDefaultPII sets whether the personally identifiable information added
+// by default is sent: the REMOTE_ADDR of requests, the headers forwarding it and
+// the IP address of users. It is sent unless disabled.
+func (client *Client) SetSendDefaultPII(enabled bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.sendDefaultPII = enabled
+}
+
+// SetScrubber sets the Scrubber of the default *Client
+func SetScrubber(scrubber *Scrubber) { DefaultClient.SetScrubber(scrubber) }
+
+// SetSendDefaultPII sets whether the default *Client sends default PII
+func SetSendDefaultPII(enabled bool) { DefaultClient.SetSendDefaultPII(enabled) }
+
+// scrubPacket masks the sensitive values of the packet and removes default PII unless it is sent
+func (client *Client) scrubPacket(packet *Packet) {
+	client.mu.RLock()
+	sendDefaultPII := client.sendDefaultPII
+	client.mu.RUnlock()
+
+	client.getScrubber().Scrub(packet)
+	if !sendDefaultPII {
+		removeDefaultPII(packet)
+	}
+}
+
+// removeDefaultPII replaces the requests and users of the packet with copies lacking client addresses
+func removeDefaultPII(packet *Packet) {
+	interfaces := make([]Interface, len(packet.Interfaces))
+	copy(interfaces, packet.Interfaces)
+	for i, inter := range interfaces {
+		switch v := inter.(type) {
+		case *Http:
+			if v == nil {
+				continue
+			}
+			h := *v
+			if v.Env != nil {
+				h.Env = make(map[string]string, len(v.Env))
+				for key, value := range v.Env {
+					if key != "REMOTE_ADDR" && key != "REMOTE_PORT" {
+						h.Env[key] = value
+					}
+				}
+			}
+			if v.Headers != nil {
+				h.Headers = make(map[string]string, len(v.Headers))
+				for key, value := range v.Headers {
+					if !isClientAddrHeader(key) {
+						h.Headers[key] = value
+					}
+				}
+			}
+			interfaces[i] = &h
+		case *User:
+			if v == nil {
+				continue
+			}
+			user := *v
+			user.IP = ""
+			interfaces[i] = &user
+		}
+	}
+	packet.Interfaces = interfaces
+}
+
+func isClientAddrHeader(key string) bool {
+	for _, header := range clientAddrHeaders {
+		if strings.EqualFold(key, header) {
+			return true
+		}
+	}
+	return false
+}
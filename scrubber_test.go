@@ -0,0 +1,180 @@
+package raven
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestScrubberScrubsHttp(t *testing.T) {
+	h := &Http{
+		URL:     "http://example.com/login",
+		Method:  "POST",
+		Query:   "next=/&access_token=abc",
+		Cookies: "theme=dark; sessionid=abc",
+		Headers: map[string]string{
+			"Authorization": "Bearer abc",
+			"Cookie":        "theme=dark; sessionid=abc",
+			"Content-Type":  "application/json",
+			"X-Api-Key":     "abc",
+			"Accept":        "*/*",
+		},
+		Data: `{"user":"bob","password":"hunter2","nested":{"Secret":"abc"}}`,
+	}
+	packet := NewPacket("login failed", h)
+	packet.Extra["token"] = "abc"
+	packet.Extra["credentials"] = map[string]interface{}{"user": "bob"}
+	packet.AddTags(map[string]string{"api_key": "abc", "route": "login"})
+
+	NewScrubber(DefaultScrubKeys).Scrub(packet)
+
+	scrubbed := packetInterface(packet, "request").(*Http)
+	if scrubbed == h || h.Headers["Authorization"] != "Bearer abc" {
+		t.Error("expected the original request to be left untouched")
+	}
+	want := &Http{
+		URL:     "http://example.com/login",
+		Method:  "POST",
+		Query:   "access_token=%2A%2A%2A%2A%2A%2A%2A%2A&next=%2F",
+		Cookies: "theme=dark; sessionid=********",
+		Headers: map[string]string{
+			"Authorization": "********",
+			"Cookie":        "theme=dark; sessionid=********",
+			"Content-Type":  "application/json",
+			"X-Api-Key":     "********",
+			"Accept":        "*/*",
+		},
+		Data: `{"nested":{"Secret":"********"},"password":"********","user":"bob"}`,
+	}
+	if !reflect.DeepEqual(scrubbed, want) {
+		t.Errorf("incorrect scrubbing: got %+v, want %+v", scrubbed, want)
+	}
+
+	if packet.Extra["token"] != "********" || packet.Extra["credentials"] != "********" {
+		t.Errorf("incorrect extra: got %v", packet.Extra)
+	}
+	if tags := packetTags(packet); tags["api_key"] != "********" || tags["route"] != "login" {
+		t.Errorf("incorrect tags: got %v", tags)
+	}
+}
+
+var scrubQueryTests = []struct {
+	input, output string
+}{
+	{"foo=bar", "foo=bar"},
+	{"password=foo", "password=********"},
+	{"passphrase=foo", "passphrase=********"},
+	{"passwd=foo", "passwd=********"},
+	{"secret=foo", "secret=********"},
+	{"secretstuff=foo", "secretstuff=********"},
+	{"foo=bar&secret=foo", "foo=bar&secret=********"},
+	{"secret=foo&secret=bar", "secret=********"},
+}
+
+func parseQuery(q string) url.Values {
+	r, _ := url.ParseQuery(q)
+	return r
+}
+
+func TestScrubberScrubsQuery(t *testing.T) {
+	s := NewScrubber(DefaultScrubKeys)
+	for _, test := range scrubQueryTests {
+		actual := s.scrubValues(parseQuery(test.input))
+		expected := parseQuery(test.output)
+		if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("incorrect sanitization: got %+v, want %+v", actual, expected)
+		}
+	}
+}
+
+func TestScrubberScrubsFormData(t *testing.T) {
+	s := NewScrubber([]string{"PIN"})
+	for _, test := range []struct {
+		data, want interface{}
+	}{
+		{map[string]string{"card": "1", "pin": "1234"}, map[string]string{"card": "1", "pin": "********"}},
+		{"card=1&pin=1234", "card=1&pin=%2A%2A%2A%2A%2A%2A%2A%2A"},
+		{"card=1&amount=2", "card=1&amount=2"},
+	} {
+		h := s.scrubHttp(&Http{
+			Headers: map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			Data:    test.data,
+		})
+		if !reflect.DeepEqual(h.Data, test.want) {
+			t.Errorf("incorrect data: got %#v, want %#v", h.Data, test.want)
+		}
+	}
+}
+
+func TestScrubberScrubsJSONData(t *testing.T) {
+	data := map[string]interface{}{
+		"user":     "bob",
+		"password": "hunter2",
+		"cards":    []interface{}{map[string]interface{}{"pin": "1234"}},
+	}
+	h := NewScrubber([]string{"password", "pin"}).scrubHttp(&Http{Data: data})
+
+	want := map[string]interface{}{
+		"user":     "bob",
+		"password": "********",
+		"cards":    []interface{}{map[string]interface{}{"pin": "********"}},
+	}
+	if !reflect.DeepEqual(h.Data, want) {
+		t.Errorf("incorrect data: got %#v, want %#v", h.Data, want)
+	}
+	if data["password"] != "hunter2" {
+		t.Error("expected the original data to be left untouched")
+	}
+}
+
+func TestClientScrubsPackets(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetSendDefaultPII(false)
+
+	h := &Http{
+		URL:     "http://example.com/",
+		Headers: map[string]string{"Authorization": "Bearer abc", "X-Forwarded-For": "10.0.0.1"},
+		Env:     map[string]string{"REMOTE_ADDR": "10.0.0.1", "REMOTE_PORT": "5000", "SERVER_NAME": "web"},
+	}
+	client.Capture(NewPacket("login failed", h, &User{ID: "42", IP: "10.0.0.1"}), nil)
+	client.SetScrubber(nil)
+	client.SetSendDefaultPII(true)
+	client.Capture(NewPacket("login failed", h), nil)
+	client.Wait()
+
+	if len(transport.packets) != 2 {
+		t.Fatalf("expected 2 packets, got %d", len(transport.packets))
+	}
+	scrubbed := packetInterface(transport.packets[0], "request").(*Http)
+	if !reflect.DeepEqual(scrubbed.Headers, map[string]string{"Authorization": "********"}) {
+		t.Errorf("incorrect headers: got %v", scrubbed.Headers)
+	}
+	if !reflect.DeepEqual(scrubbed.Env, map[string]string{"SERVER_NAME": "web"}) {
+		t.Errorf("incorrect env: got %v", scrubbed.Env)
+	}
+	if user := packetInterface(transport.packets[0], "user").(*User); user.ID != "42" || user.IP != "" {
+		t.Errorf("expected the user IP to be removed, got %+v", user)
+	}
+
+	if raw := packetInterface(transport.packets[1], "request").(*Http); !reflect.DeepEqual(raw, h) {
+		t.Errorf("expected the request to be sent as is, got %+v", raw)
+	}
+}
+
+func TestScrubberScrubsUnparsableBodies(t *testing.T) {
+	s := NewScrubber(DefaultScrubKeys)
+	for _, test := range []struct {
+		contentType, body, want string
+	}{
+		{"application/json", `{"password":"hunter2","blob":"abc...`, `{"password":"********","blob":"abc...`},
+		{"application/json", `{"user":"bob","api_key":"abc`, `{"user":"bob","api_key":"********"`},
+		{"application/json", `{"count":1,"token":12...`, `{"count":1,"token":"********"`},
+		{"text/plain", "user=bob password=hunter2", "user=bob password=********"},
+		{"multipart/form-data; boundary=x", "--x\r\nContent-Disposition: form-data; name=\"password\"\r\n\r\nhunter2", "********"},
+	} {
+		if body := s.scrubBody(test.body, test.contentType); body != test.want {
+			t.Errorf("incorrect scrubbing of %q: got %q, want %q", test.body, body, test.want)
+		}
+	}
+}
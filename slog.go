@@ -0,0 +1,238 @@
+//go:build go1.21
+// +build go1.21
+
+package raven
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"time"
+)
+
+// SlogOptions configures a SlogHandler
+type SlogOptions struct {
+	// EventLevel is the minimum level of the records captured as packets.
+	// Defaults to slog.LevelError.
+	EventLevel slog.Leveler
+
+	// BreadcrumbLevel is the minimum level of the records recorded as breadcrumbs,
+	// records below it are ignored. Defaults to slog.LevelInfo.
+	BreadcrumbLevel slog.Leveler
+
+	// TagKeys lists the attributes reported as tags, every other one is reported as extra.
+	// Keys of attributes in groups are qualified with the group names, such as "request.id".
+	TagKeys []string
+
+	// Logger name reported to Sentry
+	Logger string
+}
+
+// SlogHandler is a slog.Handler reporting records to Sentry. Records at or above
+// the event level are captured as packets, with their attributes as extra or tags.
+// The first attribute holding an error is reported as an exception with a stacktrace.
+// Records at or above the breadcrumb level are recorded as breadcrumbs, in the scope
+// carried by the context if any, or else in the client's one.
+type SlogHandler struct {
+	client          *Client
+	eventLevel      slog.Leveler
+	breadcrumbLevel slog.Leveler
+	tagKeys         map[string]bool
+	logger          string
+
+	attrs  []slog.Attr
+	prefix string
+}
+
+// NewSlogHandler returns a handler reporting to client, or to the default *Client
+// if client is nil. opts may be nil to use the defaults.
+func NewSlogHandler(client *Client, opts *SlogOptions) *SlogHandler {
+	if opts == nil {
+		opts = &SlogOptions{}
+	}
+
+	h := &SlogHandler{
+		client:          client,
+		eventLevel:      opts.EventLevel,
+		breadcrumbLevel: opts.BreadcrumbLevel,
+		tagKeys:         make(map[string]bool, len(opts.TagKeys)),
+		logger:          opts.Logger,
+	}
+	if h.eventLevel == nil {
+		h.eventLevel = slog.LevelError
+	}
+	if h.breadcrumbLevel == nil {
+		h.breadcrumbLevel = slog.LevelInfo
+	}
+	for _, key := range opts.TagKeys {
+		h.tagKeys[key] = true
+	}
+	return h
+}
+
+// Enabled reports whether records of the level are captured or recorded as breadcrumbs
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.eventLevel.Level() || level >= h.breadcrumbLevel.Level()
+}
+
+// Handle captures the record as a packet or records it as a breadcrumb, according to its level
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	client := h.client
+	if client == nil {
+		client = DefaultClient
+	}
+
+	tags := make(map[string]string)
+	extra := Extra{}
+	var err error
+	collect := func(key string, value interface{}) {
+		if e, ok := value.(error); ok {
+			if err == nil {
+				err = e
+			}
+			value = e.Error()
+		}
+		if h.tagKeys[key] {
+			tags[key] = fmt.Sprint(value)
+		} else {
+			extra[key] = value
+		}
+	}
+	for _, a := range h.attrs {
+		flattenSlogAttr("", a, collect)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		flattenSlogAttr(h.prefix, a, collect)
+		return true
+	})
+
+	if r.Level < h.eventLevel.Level() {
+		data := make(map[string]interface{}, len(tags)+len(extra))
+		for k, v := range extra {
+			data[k] = v
+		}
+		for k, v := range tags {
+			data[k] = v
+		}
+		breadcrumb := &Breadcrumb{
+			Timestamp: Timestamp(r.Time),
+			Category:  h.logger,
+			Message:   r.Message,
+			Level:     slogSeverity(r.Level),
+			Data:      data,
+		}
+		if scope := ScopeFromContext(ctx); scope != nil {
+			scope.AddBreadcrumb(breadcrumb)
+		} else {
+			client.AddBreadcrumb(breadcrumb)
+		}
+		return nil
+	}
+
+	interfaces := append(client.scopeInterfaces(ctx), &Message{Message: r.Message})
+	if err != nil {
+		for k, v := range extractExtra(err) {
+			if _, ok := extra[k]; !ok {
+				extra[k] = v
+			}
+		}
+	}
+	packet := NewPacketWithExtra(r.Message, extra, interfaces...)
+	packet.Level = slogSeverity(r.Level)
+	packet.Logger = h.logger
+	if !r.Time.IsZero() {
+		packet.Timestamp = Timestamp(r.Time)
+	}
+	if err != nil {
+		packet.AddTags(extractTags(err))
+		applyErrorDecorations(packet, err)
+		stacktrace := callerStacktrace(NewStacktrace(0, 3, client.IncludePaths()), r.PC)
+		packet.Interfaces = append(packet.Interfaces, NewExceptionChain(err, stacktrace, 3, client.IncludePaths()))
+	}
+	ScopeFromContext(ctx).applyTo(packet)
+
+	client.Capture(packet, tags)
+	return nil
+}
+
+// WithAttrs returns a handler adding attrs to every record
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	clone := *h
+	clone.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	clone.attrs = append(clone.attrs, h.attrs...)
+	for _, a := range attrs {
+		// Attributes are qualified right away, later groups don't apply to them
+		flattenSlogAttr(h.prefix, a, func(key string, value interface{}) {
+			clone.attrs = append(clone.attrs, slog.Any(key, value))
+		})
+	}
+	return &clone
+}
+
+// WithGroup returns a handler qualifying the keys of the attributes added afterwards with name
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "."
+	return &clone
+}
+
+// flattenSlogAttr calls fn with the qualified key and value of a, or of every attribute of a group
+func flattenSlogAttr(prefix string, a slog.Attr, fn func(key string, value interface{})) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		// Attributes of groups without a key are inlined
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, attr := range a.Value.Group() {
+			flattenSlogAttr(prefix, attr, fn)
+		}
+	case slog.KindDuration:
+		fn(prefix+a.Key, a.Value.Duration().String())
+	case slog.KindTime:
+		fn(prefix+a.Key, a.Value.Time().Format(time.RFC3339Nano))
+	default:
+		fn(prefix+a.Key, a.Value.Any())
+	}
+}
+
+// callerStacktrace trims the frames of the logging call from stacktrace, keeping
+// the ones up to the frame of pc, the caller of the logger
+func callerStacktrace(stacktrace *Stacktrace, pc uintptr) *Stacktrace {
+	if stacktrace == nil || pc == 0 {
+		return stacktrace
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	for i := len(stacktrace.Frames) - 1; i >= 0; i-- {
+		if f := stacktrace.Frames[i]; f.AbsolutePath == frame.File && f.Lineno == frame.Line {
+			return &Stacktrace{Frames: stacktrace.Frames[:i+1]}
+		}
+	}
+	return stacktrace
+}
+
+// slogSeverity maps slog levels to Sentry's severities
+func slogSeverity(level slog.Level) Severity {
+	switch {
+	case level < slog.LevelInfo:
+		return DEBUG
+	case level < slog.LevelWarn:
+		return INFO
+	case level < slog.LevelError:
+		return WARNING
+	default:
+		return ERROR
+	}
+}
@@ -0,0 +1,74 @@
+//go:build go1.21
+// +build go1.21
+
+package raven
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogHandlerCapturesErrors(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	logger := slog.New(NewSlogHandler(client, &SlogOptions{TagKeys: []string{"request.method"}, Logger: "app"}))
+
+	logger.With("component", "billing").WithGroup("request").Error("charge failed",
+		"method", "POST",
+		"err", WrapWithExtra(errors.New("card declined"), map[string]interface{}{"card": "visa"}),
+	)
+	client.Wait()
+
+	if len(transport.packets) != 1 {
+		t.Fatalf("expected 1 packet, got %d", len(transport.packets))
+	}
+	packet := transport.packets[0]
+	if packet.Message != "charge failed" || packet.Level != ERROR || packet.Logger != "app" {
+		t.Errorf("incorrect packet: got %q at %q from %q", packet.Message, packet.Level, packet.Logger)
+	}
+	if tags := packetTags(packet); tags["request.method"] != "POST" {
+		t.Errorf("expected attribute to be reported as a tag, got %+v", tags)
+	}
+	if packet.Extra["component"] != "billing" || packet.Extra["request.err"] != "card declined" || packet.Extra["card"] != "visa" {
+		t.Errorf("incorrect Extra: got %+v", packet.Extra)
+	}
+
+	exception, ok := packetInterface(packet, "exception").(*Exception)
+	if !ok || exception.Value != "card declined" {
+		t.Fatalf("expected the error to be reported as an exception, got %#v", packetInterface(packet, "exception"))
+	}
+	frames := exception.Stacktrace.Frames
+	if frames[len(frames)-1].Function != "TestSlogHandlerCapturesErrors" {
+		t.Errorf("expected the stacktrace to end at the logging call, got %+v", frames[len(frames)-1])
+	}
+}
+
+func TestSlogHandlerRecordsBreadcrumbs(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	handler := NewSlogHandler(client, nil)
+	logger := slog.New(handler)
+
+	if handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected debug records to be ignored by default")
+	}
+
+	ctx := WithScope(context.Background())
+	logger.InfoContext(ctx, "scoped", "id", 1)
+	logger.Debug("ignored")
+	logger.Warn("unscoped")
+	client.Wait()
+
+	if len(transport.packets) != 0 {
+		t.Errorf("expected no packet for records below the event level, got %d", len(transport.packets))
+	}
+	if messages := breadcrumbMessages(client.context.breadcrumbs.snapshot()); len(messages) != 1 || messages[0] != "unscoped" {
+		t.Errorf("incorrect client breadcrumbs: got %v", messages)
+	}
+	breadcrumbs := ScopeFromContext(ctx).breadcrumbs.snapshot()
+	if len(breadcrumbs) != 1 || breadcrumbs[0].Message != "scoped" || breadcrumbs[0].Level != INFO || breadcrumbs[0].Data["id"] != int64(1) {
+		t.Errorf("incorrect scope breadcrumbs: got %+v", breadcrumbs)
+	}
+}
@@ -0,0 +1,294 @@
+package raven
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolFileExt       = ".json"
+	spoolReplayBackoff = 100 * time.Millisecond
+)
+
+// Spool persists outgoing packets in a directory until they are delivered.
+// Packets which couldn't be sent, because the network was down, the queue was
+// full or the process died, are replayed the next time the spool is attached
+// to a Client.
+type Spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu sync.Mutex
+	// Spooled packets, oldest first, and their total size, tracked in memory so
+	// that storing a packet doesn't list the directory
+	entries []spoolEntry
+	size    int64
+}
+
+type spoolEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// NewSpool creates a spool writing packets to dir, creating it if needed.
+// When the total size of spooled packets exceeds maxSize bytes the oldest ones
+// are removed, and packets older than maxAge are never replayed. Zero disables
+// the respective cap.
+func NewSpool(dir string, maxSize int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	s := &Spool{dir: dir, maxSize: maxSize, maxAge: maxAge}
+	s.scan()
+	return s, nil
+}
+
+// store writes the packet to the spool and returns the name of the created file
+func (s *Spool) store(packet *Packet) (string, error) {
+	data, err := packet.JSON()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(int64(len(data)))
+
+	name := packet.EventID + spoolFileExt
+	path := filepath.Join(s.dir, name)
+	// Write to a temporary file first, so that a crash never leaves a partial packet behind
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	s.entries = append(s.entries, spoolEntry{name: name, size: int64(len(data)), modTime: time.Now()})
+	s.size += int64(len(data))
+	return name, nil
+}
+
+// remove deletes a delivered packet from the spool
+func (s *Spool) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, entry := range s.entries {
+		if entry.name == name {
+			s.size -= entry.size
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+		debugLogger.Println("failed to remove spooled packet:", err)
+	}
+}
+
+// load reads every spooled packet, oldest first. Files which can't be decoded are removed.
+func (s *Spool) load() (names []string, packets []*Packet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Pick up the packets spooled by other processes since the spool was created
+	s.scan()
+	s.prune(0)
+
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		path := filepath.Join(s.dir, entry.name)
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			debugLogger.Println("failed to read spooled packet:", err)
+			kept = append(kept, entry)
+			continue
+		}
+
+		packet, err := unmarshalPacket(data)
+		if err != nil {
+			debugLogger.Println("removing invalid spooled packet:", err)
+			os.Remove(path)
+			s.size -= entry.size
+			continue
+		}
+
+		kept = append(kept, entry)
+		names = append(names, entry.name)
+		packets = append(packets, packet)
+	}
+	s.entries = kept
+	return names, packets
+}
+
+// scan rebuilds the in-memory index of spooled packets from the directory.
+// It must be called with s.mu held, or before the spool is shared.
+func (s *Spool) scan() {
+	s.entries, s.size = nil, 0
+	for _, file := range s.files() {
+		s.entries = append(s.entries, spoolEntry{name: file.Name(), size: file.Size(), modTime: file.ModTime()})
+		s.size += file.Size()
+	}
+}
+
+// prune removes expired packets, then the oldest ones until there is room
+// for reserve more bytes. It does nothing unless a cap is set, and must be
+// called with s.mu held.
+func (s *Spool) prune(reserve int64) {
+	if s.maxSize <= 0 && s.maxAge <= 0 {
+		return
+	}
+
+	kept := s.entries[:0]
+	for _, entry := range s.entries {
+		expired := s.maxAge > 0 && time.Since(entry.modTime) > s.maxAge
+		if expired || s.maxSize > 0 && s.size+reserve > s.maxSize {
+			os.Remove(filepath.Join(s.dir, entry.name))
+			s.size -= entry.size
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	s.entries = kept
+}
+
+// files lists spooled packets, oldest first
+func (s *Spool) files() []os.FileInfo {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		debugLogger.Println("failed to list spool directory:", err)
+		return nil
+	}
+
+	var files []os.FileInfo
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), spoolFileExt) {
+			files = append(files, info)
+		}
+	}
+	sort.Sort(byModTime(files))
+	return files
+}
+
+type byModTime []os.FileInfo
+
+func (f byModTime) Len() int           { return len(f) }
+func (f byModTime) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+func (f byModTime) Less(i, j int) bool { return f[i].ModTime().Before(f[j].ModTime()) }
+
+// rawInterface is an already serialized Sentry interface, used for packets read back from JSON
+type rawInterface struct {
+	class string
+	data  json.RawMessage
+}
+
+// Class provides name of implemented Sentry's interface
+func (r *rawInterface) Class() string { return r.class }
+
+// MarshalJSON returns the original JSON encoding of the interface
+func (r *rawInterface) MarshalJSON() ([]byte, error) { return r.data, nil }
+
+// JSON keys of the Packet fields, every other top-level key is an interface
+var packetFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(Packet{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}()
+
+// unmarshalPacket is the reverse of Packet.JSON, interfaces are kept as raw JSON
+func unmarshalPacket(data []byte) (*Packet, error) {
+	packet := &Packet{}
+	if err := json.Unmarshal(data, packet); err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	classes := make([]string, 0, len(fields))
+	for class := range fields {
+		if !packetFields[class] {
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		packet.Interfaces = append(packet.Interfaces, &rawInterface{class, fields[class]})
+	}
+
+	return packet, nil
+}
+
+// SetSpool makes the client persist outgoing packets to s until they are delivered,
+// and replays the packets left over by a previous run. Passing nil disables spooling.
+func (client *Client) SetSpool(s *Spool) {
+	client.mu.Lock()
+	client.spool = s
+	client.mu.Unlock()
+
+	if s == nil {
+		return
+	}
+
+	names, packets := s.load()
+	if len(packets) == 0 {
+		return
+	}
+	debugLogger.Printf("replaying %d spooled packets", len(packets))
+
+	// Replayed packets count as pending right away, so that Wait and Flush cover them
+	client.wg.Add(len(packets))
+
+	go func() {
+		for i := 0; i < len(packets); {
+			err := client.enqueue(&outgoingPacket{
+				packet:    packets[i],
+				ch:        make(chan error, 1),
+				spoolFile: names[i],
+			})
+
+			switch err {
+			case nil:
+				i++
+			case ErrPacketDropped:
+				// Wait for the worker to make room in the queue
+				time.Sleep(spoolReplayBackoff)
+			default:
+				// Closed client, what's left stays in the spool for the next run
+				client.wg.Add(i - len(packets))
+				return
+			}
+		}
+	}()
+}
+
+// SetSpool sets the spool used by the default *Client
+func SetSpool(s *Spool) { DefaultClient.SetSpool(s) }
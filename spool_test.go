@@ -0,0 +1,174 @@
+package raven
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingTransport struct {
+	mu      sync.Mutex
+	err     error
+	packets []*Packet
+}
+
+func (t *recordingTransport) Send(url, authHeader string, packet *Packet) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.packets = append(t.packets, packet)
+	return t.err
+}
+
+func newTestSpool(t *testing.T, maxSize int64, maxAge time.Duration) *Spool {
+	dir, err := ioutil.TempDir("", "raven-spool")
+	if err != nil {
+		t.Fatal("failed to create temporary directory:", err)
+	}
+	spool, err := NewSpool(filepath.Join(dir, "spool"), maxSize, maxAge)
+	if err != nil {
+		t.Fatal("failed to create spool:", err)
+	}
+	return spool
+}
+
+func TestSpoolStoreAndLoad(t *testing.T) {
+	spool := newTestSpool(t, 0, 0)
+	defer os.RemoveAll(filepath.Dir(spool.dir))
+
+	packet := NewPacket("spooled", &Message{Message: "spooled"}, &User{ID: "42"})
+	packet.Tags = Tags{{"foo", "bar"}}
+	if err := packet.Init("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := spool.store(packet)
+	if err != nil {
+		t.Fatal("failed to store packet:", err)
+	}
+
+	names, packets := spool.load()
+	if len(packets) != 1 || names[0] != name {
+		t.Fatalf("expected to load the stored packet, got %v", names)
+	}
+
+	expected, _ := packet.JSON()
+	actual, err := packets[0].JSON()
+	if err != nil {
+		t.Fatal("failed to marshal loaded packet:", err)
+	}
+	if string(actual) != string(expected) {
+		t.Errorf("incorrect packet: got %s, want %s", actual, expected)
+	}
+
+	spool.remove(name)
+	if _, packets = spool.load(); len(packets) != 0 {
+		t.Errorf("expected removed packet not to be loaded, got %d packets", len(packets))
+	}
+}
+
+func TestSpoolPrunesBySize(t *testing.T) {
+	spool := newTestSpool(t, 1, 0)
+	defer os.RemoveAll(filepath.Dir(spool.dir))
+
+	for i := 0; i < 3; i++ {
+		packet := NewPacket("spooled")
+		packet.Init("1")
+		if _, err := spool.store(packet); err != nil {
+			t.Fatal("failed to store packet:", err)
+		}
+	}
+
+	if files := spool.files(); len(files) != 1 {
+		t.Errorf("expected only the newest packet to be kept, got %d files", len(files))
+	}
+}
+
+func TestSpoolTracksSizeInMemory(t *testing.T) {
+	spool := newTestSpool(t, 0, 0)
+	defer os.RemoveAll(filepath.Dir(spool.dir))
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		packet := NewPacket("spooled")
+		packet.Init("1")
+		name, err := spool.store(packet)
+		if err != nil {
+			t.Fatal("failed to store packet:", err)
+		}
+		names = append(names, name)
+	}
+
+	var size int64
+	for _, file := range spool.files() {
+		size += file.Size()
+	}
+	if len(spool.entries) != 2 || spool.size != size {
+		t.Errorf("incorrect index: got %d entries of %d bytes, want 2 entries of %d bytes", len(spool.entries), spool.size, size)
+	}
+
+	spool.remove(names[0])
+	files := spool.files()
+	if len(spool.entries) != 1 || spool.entries[0].name != names[1] || len(files) != 1 || spool.size != files[0].Size() {
+		t.Errorf("expected the removed packet to leave the index, got %+v of %d bytes", spool.entries, spool.size)
+	}
+
+	// A reopened spool indexes the packets left in the directory
+	reopened, err := NewSpool(spool.dir, 0, 0)
+	if err != nil {
+		t.Fatal("failed to reopen spool:", err)
+	}
+	if len(reopened.entries) != 1 || reopened.size != spool.size {
+		t.Errorf("incorrect index of reopened spool: got %+v of %d bytes", reopened.entries, reopened.size)
+	}
+}
+
+func TestSpoolPrunesByAge(t *testing.T) {
+	spool := newTestSpool(t, 0, time.Hour)
+	defer os.RemoveAll(filepath.Dir(spool.dir))
+
+	packet := NewPacket("spooled")
+	packet.Init("1")
+	name, err := spool.store(packet)
+	if err != nil {
+		t.Fatal("failed to store packet:", err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(spool.dir, name), old, old)
+
+	if _, packets := spool.load(); len(packets) != 0 {
+		t.Errorf("expected expired packet to be dropped, got %d packets", len(packets))
+	}
+}
+
+func TestClientReplaysSpool(t *testing.T) {
+	spool := newTestSpool(t, 0, 0)
+	defer os.RemoveAll(filepath.Dir(spool.dir))
+
+	failing := &recordingTransport{err: errors.New("network is down")}
+	client := newTestClient(failing)
+	client.SetSpool(spool)
+
+	_, ch := client.Capture(NewPacket("offline"), nil)
+	if err := <-ch; err == nil {
+		t.Fatal("expected delivery to fail")
+	}
+	if files := spool.files(); len(files) != 1 {
+		t.Fatalf("expected failed packet to stay in the spool, got %d files", len(files))
+	}
+
+	working := &recordingTransport{}
+	client = newTestClient(working)
+	client.SetSpool(spool)
+	client.Wait()
+
+	if len(working.packets) != 1 || working.packets[0].Message != "offline" {
+		t.Fatalf("expected spooled packet to be replayed, got %+v", working.packets)
+	}
+	if files := spool.files(); len(files) != 0 {
+		t.Errorf("expected delivered packet to be removed from the spool, got %d files", len(files))
+	}
+}
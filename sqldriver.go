@@ -0,0 +1,281 @@
+//go:build go1.10
+// +build go1.10
+
+package raven
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	// Literal values removed from recorded statements
+	sqlStringPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+	sqlNumberPattern = regexp.MustCompile(`(^|[^\w$.])-?\d+(?:\.\d+)?`)
+)
+
+// WrapDriver wraps a database/sql driver so that every executed statement is recorded
+// as a breadcrumb, with literal values scrubbed, in the scope carried by the context of
+// the call if any, or else in the default *Client's one. A failing statement is also
+// set as the Query interface of the scope carried by the context, until the next
+// statement succeeds, so that it is reported along with errors captured with
+// CaptureErrorCtx. name is reported as the database engine.
+//
+// The wrapped driver is meant to be registered under a new name:
+//
+//	sql.Register("sentry-postgres", raven.WrapDriver("postgres", &pq.Driver{}))
+//	db, err := sql.Open("sentry-postgres", dsn)
+func WrapDriver(name string, d driver.Driver) driver.Driver {
+	return &sqlDriver{engine: name, driver: d}
+}
+
+type sqlDriver struct {
+	engine string
+	driver driver.Driver
+}
+
+func (d *sqlDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &sqlConn{engine: d.engine, conn: conn}, nil
+}
+
+// recordQuery adds the statement to the scope of ctx, or to the default client
+func recordQuery(ctx context.Context, engine, query string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+
+	query = scrubQuery(query)
+	breadcrumb := &Breadcrumb{
+		Category: "query",
+		Message:  query,
+		Level:    INFO,
+		Data: map[string]interface{}{
+			"engine":   engine,
+			"duration": time.Since(start).String(),
+		},
+	}
+	if err != nil {
+		breadcrumb.Level = ERROR
+		breadcrumb.Data["error"] = err.Error()
+	}
+
+	scope := ScopeFromContext(ctx)
+	if scope == nil {
+		DefaultClient.AddBreadcrumb(breadcrumb)
+		return
+	}
+	scope.AddBreadcrumb(breadcrumb)
+	if err != nil {
+		scope.SetQuery(&Query{Query: query, Engine: engine})
+	} else {
+		// The failure was recovered from, later errors aren't caused by it
+		scope.SetQuery(nil)
+	}
+}
+
+// scrubQuery replaces the string and number literals of a statement with "?"
+func scrubQuery(query string) string {
+	query = sqlStringPattern.ReplaceAllString(query, "?")
+	return sqlNumberPattern.ReplaceAllString(query, "${1}?")
+}
+
+type sqlConn struct {
+	engine string
+	conn   driver.Conn
+}
+
+func (c *sqlConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *sqlConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.conn.Prepare(query)
+	}
+	if err != nil {
+		recordQuery(ctx, c.engine, query, start, err)
+		return nil, err
+	}
+	return &sqlStmt{engine: c.engine, query: query, stmt: stmt, conn: c.conn}, nil
+}
+
+func (c *sqlConn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *sqlConn) Begin() (driver.Tx, error) {
+	return c.conn.Begin()
+}
+
+func (c *sqlConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("raven: driver does not support non-default transaction options")
+	}
+	return c.conn.Begin()
+}
+
+func (c *sqlConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	switch execer := c.conn.(type) {
+	case driver.ExecerContext:
+		res, err = execer.ExecContext(ctx, query, args)
+	case driver.Execer:
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			res, err = execer.Exec(query, values)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	recordQuery(ctx, c.engine, query, start, err)
+	return res, err
+}
+
+func (c *sqlConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	switch queryer := c.conn.(type) {
+	case driver.QueryerContext:
+		rows, err = queryer.QueryContext(ctx, query, args)
+	case driver.Queryer:
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = queryer.Query(query, values)
+		}
+	default:
+		return nil, driver.ErrSkip
+	}
+	recordQuery(ctx, c.engine, query, start, err)
+	return rows, err
+}
+
+func (c *sqlConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *sqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+type sqlStmt struct {
+	engine string
+	query  string
+	stmt   driver.Stmt
+	conn   driver.Conn
+}
+
+func (s *sqlStmt) Close() error {
+	return s.stmt.Close()
+}
+
+func (s *sqlStmt) NumInput() int {
+	return s.stmt.NumInput()
+}
+
+func (s *sqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	res, err := s.stmt.Exec(args)
+	recordQuery(context.Background(), s.engine, s.query, start, err)
+	return res, err
+}
+
+func (s *sqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.stmt.Query(args)
+	recordQuery(context.Background(), s.engine, s.query, start, err)
+	return rows, err
+}
+
+func (s *sqlStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if execer, ok := s.stmt.(driver.StmtExecContext); ok {
+		res, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			res, err = s.stmt.Exec(values)
+		}
+	}
+	recordQuery(ctx, s.engine, s.query, start, err)
+	return res, err
+}
+
+func (s *sqlStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if queryer, ok := s.stmt.(driver.StmtQueryContext); ok {
+		rows, err = queryer.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedValuesToValues(args); err == nil {
+			rows, err = s.stmt.Query(values)
+		}
+	}
+	recordQuery(ctx, s.engine, s.query, start, err)
+	return rows, err
+}
+
+// CheckNamedValue hands the value to the checks database/sql would have used
+// with the wrapped statement, since they are hidden behind the wrapper
+func (s *sqlStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.stmt.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if checker, ok := s.conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	if converter, ok := s.stmt.(driver.ColumnConverter); ok && nv.Ordinal > 0 {
+		value, err := converter.ColumnConverter(nv.Ordinal - 1).ConvertValue(nv.Value)
+		if err != nil {
+			return err
+		}
+		nv.Value = value
+		return nil
+	}
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("raven: driver does not support the use of named parameters")
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,125 @@
+//go:build go1.10
+// +build go1.10
+
+package raven
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeDriver fails the statements containing "fail"
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct{ query string }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if strings.Contains(s.query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if strings.Contains(s.query, "fail") {
+		return nil, errors.New("syntax error")
+	}
+	return fakeRows{}, nil
+}
+
+type fakeRows struct{}
+
+func (fakeRows) Columns() []string              { return []string{"id"} }
+func (fakeRows) Close() error                   { return nil }
+func (fakeRows) Next(dest []driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("raven-fake", WrapDriver("fake", fakeDriver{}))
+}
+
+func TestScrubQuery(t *testing.T) {
+	query := scrubQuery("SELECT * FROM users2 WHERE name = 'O''Brien' AND age > 42 AND score < -1.5 AND id = $1")
+	expected := "SELECT * FROM users2 WHERE name = ? AND age > ? AND score < ? AND id = $1"
+	if query != expected {
+		t.Errorf("incorrect query: got %q, want %q", query, expected)
+	}
+}
+
+func TestWrapDriverRecordsQueries(t *testing.T) {
+	db, err := sql.Open("raven-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := WithScope(context.Background())
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = 'bob' WHERE id = 7"); err != nil {
+		t.Fatal("exec failed:", err)
+	}
+	if _, err := db.QueryContext(ctx, "SELECT fail FROM users WHERE id = ?", 7); err == nil {
+		t.Fatal("expected the query to fail")
+	}
+
+	scope := ScopeFromContext(ctx)
+	breadcrumbs := scope.breadcrumbs.snapshot()
+	if len(breadcrumbs) != 2 {
+		t.Fatalf("expected 2 breadcrumbs, got %d", len(breadcrumbs))
+	}
+	if breadcrumbs[0].Message != "UPDATE users SET name = ? WHERE id = ?" || breadcrumbs[0].Data["engine"] != "fake" {
+		t.Errorf("incorrect breadcrumb: got %+v", breadcrumbs[0])
+	}
+	if breadcrumbs[1].Level != ERROR || breadcrumbs[1].Data["error"] != "syntax error" {
+		t.Errorf("expected the failing statement to be recorded as an error, got %+v", breadcrumbs[1])
+	}
+
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.CaptureErrorCtx(ctx, errors.New("syntax error"), nil)
+	client.Wait()
+
+	query, ok := packetInterface(transport.packets[0], "query").(*Query)
+	if !ok || query.Query != "SELECT fail FROM users WHERE id = ?" || query.Engine != "fake" {
+		t.Errorf("expected the failing statement to be attached, got %+v", packetInterface(transport.packets[0], "query"))
+	}
+}
+
+func TestWrapDriverClearsQueryAfterSuccess(t *testing.T) {
+	db, err := sql.Open("raven-fake", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := WithScope(context.Background())
+	if _, err := db.ExecContext(ctx, "UPDATE fail SET name = 'bob'"); err == nil {
+		t.Fatal("expected the statement to fail")
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE users SET name = 'bob'"); err != nil {
+		t.Fatal("exec failed:", err)
+	}
+
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.CaptureErrorCtx(ctx, errors.New("user not found"), nil)
+	client.Wait()
+
+	if query := packetInterface(transport.packets[0], "query"); query != nil {
+		t.Errorf("expected no query once a statement succeeded, got %+v", query)
+	}
+}
@@ -54,12 +54,20 @@ type StacktraceFrame struct {
 
 // GetOrNewStacktrace tries to get stacktrace from err as an interface of github.com/pkg/errors, or else NewStacktrace()
 func GetOrNewStacktrace(err error, skip int, context int, appPackagePrefixes []string) *Stacktrace {
+	if stacktrace := errorStacktrace(err, context, appPackagePrefixes); stacktrace != nil {
+		return stacktrace
+	}
+	return NewStacktrace(skip+1, context, appPackagePrefixes)
+}
+
+// errorStacktrace returns the stacktrace recorded by err, or nil if it doesn't carry one
+func errorStacktrace(err error, context int, appPackagePrefixes []string) *Stacktrace {
 	type stackTracer interface {
 		StackTrace() []runtime.Frame
 	}
 	stacktrace, ok := err.(stackTracer)
 	if !ok {
-		return NewStacktrace(skip+1, context, appPackagePrefixes)
+		return nil
 	}
 	var frames []*StacktraceFrame
 	for _, f := range stacktrace.StackTrace() {
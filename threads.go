@@ -0,0 +1,242 @@
+package raven
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxGoroutineDumpSize caps the buffer used to dump all goroutines
+const maxGoroutineDumpSize = 16 << 20
+
+// Threads defines Sentry's spec compliant interface holding Threads information - https://develop.sentry.dev/sdk/event-payloads/threads/
+type Threads struct {
+	// Required
+	Values []*Thread `json:"values"`
+}
+
+// Class provides name of implemented Sentry's interface
+func (t *Threads) Class() string { return "threads" }
+
+// Thread is a single goroutine of a Threads interface
+type Thread struct {
+	// Required
+	ID int `json:"id"`
+
+	// Optional
+	Name       string      `json:"name,omitempty"`
+	State      string      `json:"state,omitempty"`
+	Crashed    bool        `json:"crashed,omitempty"`
+	Current    bool        `json:"current,omitempty"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+
+	// Wait is how long the goroutine has been blocked, as reported by the runtime
+	// with a one minute precision. It is part of the Name sent to Sentry.
+	Wait time.Duration `json:"-"`
+}
+
+// NewThreads dumps the stacks of all goroutines. The calling goroutine comes first,
+// marked as crashed and current, without a stacktrace since it's expected to be
+// reported by the exception.
+func NewThreads(context int, appPackagePrefixes []string) *Threads {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) || len(buf) >= maxGoroutineDumpSize {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	threads := parseGoroutines(buf, context, appPackagePrefixes)
+	if len(threads) == 0 {
+		return nil
+	}
+	threads[0].Crashed = true
+	threads[0].Current = true
+	threads[0].Stacktrace = nil
+	return &Threads{Values: threads}
+}
+
+// parseGoroutines parses goroutine stacks in the format of runtime.Stack and
+// unrecovered panics. Text before the first goroutine header is ignored.
+func parseGoroutines(dump []byte, context int, appPackagePrefixes []string) []*Thread {
+	var threads []*Thread
+	var current *Thread
+	var function string
+
+	scanner := bufio.NewScanner(bytes.NewReader(dump))
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if thread, ok := parseGoroutineHeader(line); ok {
+			current, function = thread, ""
+			threads = append(threads, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch {
+		case line == "":
+			current, function = nil, ""
+		case strings.HasPrefix(line, "\t"):
+			if function == "" {
+				continue
+			}
+			file, lineno := parseFileLine(strings.TrimSpace(line))
+			if frame := NewStacktraceFrame(0, function, file, lineno, context, appPackagePrefixes); frame != nil {
+				if current.Stacktrace == nil {
+					current.Stacktrace = &Stacktrace{}
+				}
+				current.Stacktrace.Frames = append(current.Stacktrace.Frames, frame)
+			}
+			function = ""
+		case strings.HasPrefix(line, "..."):
+			// Frames elided by the runtime, nothing to report
+			function = ""
+		default:
+			function = parseFunctionLine(line)
+		}
+	}
+
+	// Sentry wants the frames with the oldest first, so reverse them
+	for _, thread := range threads {
+		if thread.Stacktrace == nil {
+			continue
+		}
+		frames := thread.Stacktrace.Frames
+		for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+			frames[i], frames[j] = frames[j], frames[i]
+		}
+	}
+	return threads
+}
+
+// ParseGoTrace parses the textual stack dump of a panic, runtime.Stack or debug.Stack
+// into one Stacktrace per goroutine, in the order they appear. It's meant to report
+// crashes for which only the output is available. Frames are considered "in app"
+// according to the IncludePaths of the default *Client.
+func ParseGoTrace(text []byte) ([]*Stacktrace, error) {
+	threads := parseGoroutines(text, 3, DefaultClient.IncludePaths())
+	if len(threads) == 0 {
+		return nil, ErrInvalidTrace
+	}
+
+	stacktraces := make([]*Stacktrace, len(threads))
+	for i, thread := range threads {
+		stacktraces[i] = thread.Stacktrace
+		if stacktraces[i] == nil {
+			stacktraces[i] = &Stacktrace{}
+		}
+	}
+	return stacktraces, nil
+}
+
+// parseGoroutineHeader parses lines such as "goroutine 7 [chan receive, 5 minutes]:"
+func parseGoroutineHeader(line string) (*Thread, bool) {
+	if !strings.HasPrefix(line, "goroutine ") || !strings.HasSuffix(line, "]:") {
+		return nil, false
+	}
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "goroutine "), "]:")
+
+	i := strings.Index(line, " [")
+	if i < 0 {
+		return nil, false
+	}
+	// Tracebacks may add details after the ID, such as "goroutine 1 gp=0xc000002380 m=0 [running]:"
+	fields := strings.Fields(line[:i])
+	if len(fields) == 0 {
+		return nil, false
+	}
+	id, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, false
+	}
+
+	thread := &Thread{ID: id, Name: fmt.Sprintf("goroutine %d", id)}
+	for n, part := range strings.Split(line[i+2:], ", ") {
+		if n == 0 {
+			thread.State = part
+			continue
+		}
+		if minutes := strings.TrimSuffix(strings.TrimSuffix(part, " minutes"), " minute"); minutes != part {
+			if m, err := strconv.Atoi(minutes); err == nil {
+				thread.Wait = time.Duration(m) * time.Minute
+				thread.Name = fmt.Sprintf("%s (waiting %s)", thread.Name, part)
+			}
+		}
+	}
+	return thread, true
+}
+
+// parseFunctionLine returns the function of lines such as "main.(*T).run(0xc000010000, 0x1)"
+// or "created by main.main in goroutine 1"
+func parseFunctionLine(line string) string {
+	if strings.HasPrefix(line, "created by ") {
+		line = strings.TrimPrefix(line, "created by ")
+		if i := strings.Index(line, " in goroutine "); i >= 0 {
+			line = line[:i]
+		}
+		return line
+	}
+	if strings.HasSuffix(line, ")") {
+		if i := strings.LastIndex(line, "("); i > 0 {
+			line = line[:i]
+		}
+	}
+	return line
+}
+
+// parseFileLine parses lines such as "/src/main.go:12 +0x1d"
+func parseFileLine(line string) (string, int) {
+	if i := strings.LastIndex(line, " +0x"); i >= 0 {
+		line = line[:i]
+	}
+	i := strings.LastIndex(line, ":")
+	if i < 0 {
+		return line, 0
+	}
+	lineno, err := strconv.Atoi(line[i+1:])
+	if err != nil {
+		return line, 0
+	}
+	return line[:i], lineno
+}
+
+// SetCaptureGoroutines makes the client attach the stacks of all goroutines to
+// the panics captured with CapturePanic, CapturePanicAndWait and Recoverer.
+func (client *Client) SetCaptureGoroutines(enabled bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.captureGoroutines = enabled
+}
+
+// SetCaptureGoroutines makes the default *Client attach the stacks of all goroutines to captured panics
+func SetCaptureGoroutines(enabled bool) { DefaultClient.SetCaptureGoroutines(enabled) }
+
+// panicInterfaces returns the interfaces attached to captured panics, on top of the exception
+func (client *Client) panicInterfaces() []Interface {
+	if client == nil {
+		return nil
+	}
+	client.mu.RLock()
+	enabled := client.captureGoroutines
+	includePaths := client.includePaths
+	client.mu.RUnlock()
+
+	if !enabled {
+		return nil
+	}
+	if threads := NewThreads(3, includePaths); threads != nil {
+		return []Interface{threads}
+	}
+	return nil
+}
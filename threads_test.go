@@ -0,0 +1,98 @@
+package raven
+
+import (
+	"testing"
+	"time"
+)
+
+const goroutineDump = `goroutine 1 [running]:
+main.main()
+	/src/app/main.go:12 +0x1d
+
+goroutine 7 [chan receive, 5 minutes]:
+github.com/example/app/worker.(*Pool).run(0xc000010000, 0x1)
+	/src/app/worker/pool.go:42 +0x65
+...additional frames elided...
+created by github.com/example/app/worker.NewPool in goroutine 1
+	/src/app/worker/pool.go:20 +0x8f
+`
+
+func TestParseGoroutines(t *testing.T) {
+	threads := parseGoroutines([]byte(goroutineDump), 0, []string{"github.com/example/app"})
+	if len(threads) != 2 {
+		t.Fatalf("incorrect number of goroutines: got %d, want 2", len(threads))
+	}
+
+	thread := threads[1]
+	if thread.ID != 7 || thread.State != "chan receive" || thread.Wait != 5*time.Minute {
+		t.Errorf("incorrect goroutine: got %+v", thread)
+	}
+	if thread.Name != "goroutine 7 (waiting 5 minutes)" {
+		t.Errorf("incorrect Name: got %q", thread.Name)
+	}
+
+	frames := thread.Stacktrace.Frames
+	if len(frames) != 2 {
+		t.Fatalf("incorrect number of frames: got %d, want 2", len(frames))
+	}
+	if frames[0].Function != "NewPool" || frames[0].Lineno != 20 {
+		t.Errorf("expected the creating frame first, got %+v", frames[0])
+	}
+	module, function := functionName("github.com/example/app/worker.(*Pool).run")
+	if frames[1].Module != module || frames[1].Function != function || !frames[1].InApp {
+		t.Errorf("incorrect frame: got %+v", frames[1])
+	}
+	if frames[1].AbsolutePath != "/src/app/worker/pool.go" || frames[1].Lineno != 42 {
+		t.Errorf("incorrect location: got %s:%d", frames[1].AbsolutePath, frames[1].Lineno)
+	}
+}
+
+func TestCapturePanicWithGoroutines(t *testing.T) {
+	transport := &recordingTransport{}
+	client := newTestClient(transport)
+	client.SetCaptureGoroutines(true)
+
+	block := make(chan struct{})
+	defer close(block)
+	go func() { <-block }()
+
+	client.CapturePanic(func() { panic("oops") }, nil)
+	client.Wait()
+
+	threads, ok := packetInterface(transport.packets[0], "threads").(*Threads)
+	if !ok || len(threads.Values) < 2 {
+		t.Fatalf("expected the goroutines to be attached, got %+v", packetInterface(transport.packets[0], "threads"))
+	}
+	if crashed := threads.Values[0]; !crashed.Crashed || !crashed.Current || crashed.Stacktrace != nil {
+		t.Errorf("expected the first goroutine to be the crashed one, got %+v", crashed)
+	}
+	for _, thread := range threads.Values[1:] {
+		if thread.Crashed || thread.Stacktrace == nil {
+			t.Errorf("incorrect goroutine: got %+v", thread)
+		}
+	}
+}
+
+func TestParseGoTrace(t *testing.T) {
+	trace := "panic: runtime error: index out of range [3] with length 1\n\n" + goroutineDump + "exit status 2\n"
+
+	stacktraces, err := ParseGoTrace([]byte(trace))
+	if err != nil {
+		t.Fatal("failed to parse trace:", err)
+	}
+	if len(stacktraces) != 2 {
+		t.Fatalf("incorrect number of stacktraces: got %d, want 2", len(stacktraces))
+	}
+	if frames := stacktraces[0].Frames; len(frames) != 1 || frames[0].Function != "main" || frames[0].Lineno != 12 {
+		t.Errorf("incorrect frames of the first goroutine: got %+v", frames)
+	}
+	if frames := stacktraces[1].Frames; len(frames) != 2 {
+		t.Errorf("incorrect number of frames of the second goroutine: got %d, want 2", len(frames))
+	}
+}
+
+func TestParseGoTraceWithoutGoroutine(t *testing.T) {
+	if _, err := ParseGoTrace([]byte("exit status 1\n")); err != ErrInvalidTrace {
+		t.Errorf("expected ErrInvalidTrace, got %v", err)
+	}
+}
@@ -1,21 +1,197 @@
 package raven
 
-// Writer holds all the information about the message that will be reported to Sentry
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRateLimitedLines bounds how many distinct lines a Writer remembers for rate limiting
+	maxRateLimitedLines = 1000
+
+	// maxLineLength bounds how much of a line a Writer buffers before sending it
+	maxLineLength = 64 * 1024
+)
+
+var (
+	// Timestamps of the standard log package, RFC 3339 and similar formats
+	timestampPattern = regexp.MustCompile(`\A(\d{4}[/-]\d{2}[/-]\d{2}([T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?)?|\d{2}:\d{2}:\d{2}(\.\d+)?)\s+`)
+	// Levels such as "[ERROR]", "level=warn" or `level="info"`
+	bracketLevelPattern = regexp.MustCompile(`\[([A-Za-z]+)\]`)
+	logfmtLevelPattern  = regexp.MustCompile(`(?:\A|\s)(?:level|lvl)="?([A-Za-z]+)`)
+)
+
+// Writer holds all the information about the message that will be reported to Sentry.
+// It can be used as the output of a log.Logger.
+//
+// Writes are buffered until a newline, and every line is reported as a message, along
+// with the lines following it which start with a space or a tab, such as stacktraces.
+// Lines longer than 64KB are reported in several messages. The severity of a message
+// is detected from prefixes such as "[ERROR]", "level=warn" or the "level" field of
+// JSON records, and defaults to Level. Leading timestamps are removed, and the
+// message of JSON records is their "msg" field.
 type Writer struct {
 	Client *Client
 	Level  Severity
 	Logger string // Logger name reported to Sentry
+
+	// RateLimit is the interval within which identical messages are only reported once.
+	// Zero reports every message.
+	RateLimit time.Duration
+
+	mu       sync.Mutex
+	buf      []byte
+	lastSent map[string]time.Time
 }
 
 // Write formats the byte slice p into a string, and sends a message to
-// Sentry at the severity level indicated by the Writer w.
+// Sentry for every complete line, at the severity level detected in the line
+// or else indicated by the Writer w.
 func (w *Writer) Write(p []byte) (int, error) {
-	message := string(p)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	end := bytes.LastIndexByte(w.buf, '\n')
+	if end < 0 {
+		w.flushLongLine()
+		return len(p), nil
+	}
+
+	var record []string
+	for _, line := range strings.Split(string(w.buf[:end]), "\n") {
+		if len(record) > 0 && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			w.report(strings.Join(record, "\n"))
+			record = record[:0]
+		}
+		record = append(record, line)
+	}
+	w.report(strings.Join(record, "\n"))
+	w.buf = append(w.buf[:0], w.buf[end+1:]...)
+	w.flushLongLine()
+
+	return len(p), nil
+}
+
+// flushLongLine sends the buffered partial line in chunks of maxLineLength, so that
+// output without newlines doesn't grow the buffer unbounded. It must be called with w.mu held.
+func (w *Writer) flushLongLine() {
+	if len(w.buf) < maxLineLength {
+		return
+	}
+	rest := w.buf
+	for len(rest) >= maxLineLength {
+		w.report(string(rest[:maxLineLength]))
+		rest = rest[maxLineLength:]
+	}
+	w.buf = append([]byte(nil), rest...)
+}
+
+// Flush sends the buffered partial line, if any
+func (w *Writer) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) > 0 {
+		w.report(string(w.buf))
+		w.buf = w.buf[:0]
+	}
+}
+
+// report sends a message for the record. It must be called with w.mu held.
+func (w *Writer) report(record string) {
+	message, level := w.parse(record)
+	if strings.TrimSpace(message) == "" || w.rateLimited(message) {
+		return
+	}
 
 	packet := NewPacket(message, &Message{message, nil})
-	packet.Level = w.Level
+	packet.Level = level
 	packet.Logger = w.Logger
 	w.Client.Capture(packet, nil)
+}
 
-	return len(p), nil
+// parse strips the timestamp from the record and detects its level
+func (w *Writer) parse(record string) (string, Severity) {
+	record = strings.TrimRight(record, "\r")
+	if strings.HasPrefix(record, "{") {
+		var fields struct {
+			Level   string `json:"level"`
+			Message string `json:"msg"`
+		}
+		if json.Unmarshal([]byte(record), &fields) == nil {
+			message := record
+			if fields.Message != "" {
+				message = fields.Message
+			}
+			if level, ok := parseSeverity(fields.Level); ok {
+				return message, level
+			}
+			return message, w.Level
+		}
+	}
+
+	record = timestampPattern.ReplaceAllString(record, "")
+
+	firstLine := record
+	if i := strings.IndexByte(firstLine, '\n'); i >= 0 {
+		firstLine = firstLine[:i]
+	}
+	for _, pattern := range []*regexp.Regexp{bracketLevelPattern, logfmtLevelPattern} {
+		if m := pattern.FindStringSubmatch(firstLine); m != nil {
+			if level, ok := parseSeverity(m[1]); ok {
+				return record, level
+			}
+		}
+	}
+	return record, w.Level
+}
+
+// rateLimited reports whether the message was already sent within the rate limit
+// interval, and records it otherwise. It must be called with w.mu held.
+func (w *Writer) rateLimited(message string) bool {
+	if w.RateLimit <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	if sent, ok := w.lastSent[message]; ok && now.Sub(sent) < w.RateLimit {
+		return true
+	}
+
+	if w.lastSent == nil {
+		w.lastSent = make(map[string]time.Time)
+	}
+	if len(w.lastSent) >= maxRateLimitedLines {
+		for m, sent := range w.lastSent {
+			if now.Sub(sent) >= w.RateLimit {
+				delete(w.lastSent, m)
+			}
+		}
+	}
+	if len(w.lastSent) < maxRateLimitedLines {
+		w.lastSent[message] = now
+	}
+	return false
+}
+
+// parseSeverity maps the level names used by common loggers to Sentry's severities
+func parseSeverity(level string) (Severity, bool) {
+	switch strings.ToLower(level) {
+	case "trace", "debug":
+		return DEBUG, true
+	case "info", "notice":
+		return INFO, true
+	case "warn", "warning":
+		return WARNING, true
+	case "err", "error":
+		return ERROR, true
+	case "crit", "critical", "fatal", "panic":
+		return FATAL, true
+	}
+	return "", false
 }
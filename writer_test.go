@@ -0,0 +1,93 @@
+package raven
+
+import (
+	"bytes"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestWriterBuffersLines(t *testing.T) {
+	transport := &recordingTransport{}
+	w := &Writer{Client: newTestClient(transport), Level: ERROR, Logger: "app"}
+
+	w.Write([]byte("2009/11/10 23:00:00 first"))
+	w.Write([]byte(" record\nsecond record\n\tat main.go:12\nthird"))
+	w.Client.Wait()
+
+	if len(transport.packets) != 2 {
+		t.Fatalf("expected 2 packets for the complete lines, got %d", len(transport.packets))
+	}
+	if message := transport.packets[0].Message; message != "first record" {
+		t.Errorf("incorrect message: got %q", message)
+	}
+	if message := transport.packets[1].Message; message != "second record\n\tat main.go:12" {
+		t.Errorf("expected continuation lines to be kept with their record, got %q", message)
+	}
+	if transport.packets[0].Level != ERROR || transport.packets[0].Logger != "app" {
+		t.Errorf("incorrect packet: got %q from %q", transport.packets[0].Level, transport.packets[0].Logger)
+	}
+
+	w.Flush()
+	w.Client.Wait()
+	if len(transport.packets) != 3 || transport.packets[2].Message != "third" {
+		t.Errorf("expected Flush to send the partial line, got %d packets", len(transport.packets))
+	}
+}
+
+func TestWriterCapsLineLength(t *testing.T) {
+	transport := &recordingTransport{}
+	w := &Writer{Client: newTestClient(transport), Level: ERROR}
+
+	chunk := bytes.Repeat([]byte("x"), 1000)
+	for i := 0; i < 2*maxLineLength/len(chunk)+1; i++ {
+		w.Write(chunk)
+	}
+	w.Client.Wait()
+
+	if len(transport.packets) != 2 {
+		t.Fatalf("expected the long line to be sent in 2 packets, got %d", len(transport.packets))
+	}
+	if len(transport.packets[0].Message) != maxLineLength {
+		t.Errorf("incorrect message length: got %d, want %d", len(transport.packets[0].Message), maxLineLength)
+	}
+	if len(w.buf) >= maxLineLength {
+		t.Errorf("expected the buffer to be capped, got %d bytes", len(w.buf))
+	}
+}
+
+func TestWriterDetectsLevel(t *testing.T) {
+	tests := []struct {
+		line    string
+		message string
+		level   Severity
+	}{
+		{"2009/11/10 23:00:00.123456 [WARN] disk almost full", "[WARN] disk almost full", WARNING},
+		{"2006-01-02T15:04:05Z time=now level=debug msg=connected", "time=now level=debug msg=connected", DEBUG},
+		{`{"time":"2006-01-02T15:04:05Z","level":"INFO","msg":"started"}`, "started", INFO},
+		{"12:04:05 no level here", "no level here", ERROR},
+	}
+
+	for _, test := range tests {
+		w := &Writer{Level: ERROR}
+		message, level := w.parse(test.line)
+		if message != test.message || level != test.level {
+			t.Errorf("incorrect parsing of %q: got %q at %q, want %q at %q", test.line, message, level, test.message, test.level)
+		}
+	}
+}
+
+func TestWriterRateLimitsIdenticalLines(t *testing.T) {
+	transport := &recordingTransport{}
+	w := &Writer{Client: newTestClient(transport), Level: ERROR, RateLimit: time.Hour}
+
+	logger := log.New(w, "", log.LstdFlags)
+	logger.Print("connection lost")
+	logger.Print("connection lost")
+	logger.Print("connection restored")
+	w.Client.Wait()
+
+	if len(transport.packets) != 2 {
+		t.Errorf("expected the repeated line to be sent once, got %d packets", len(transport.packets))
+	}
+}